@@ -2,6 +2,7 @@ package api
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/bitrise-io/go-utils/log"
@@ -21,8 +22,8 @@ type ArtifactLister struct {
 	maxConcurrentShowArtifactAPICalls int
 }
 
-func NewArtifactLister(apiBaseURL, authToken string, logger log.Logger) (ArtifactLister, error) {
-	client, err := NewDefaultBitriseAPIClient(apiBaseURL, authToken)
+func NewArtifactLister(apiBaseURL, authToken string, logger log.Logger, opts ...ClientOption) (ArtifactLister, error) {
+	client, err := NewDefaultBitriseAPIClient(apiBaseURL, authToken, opts...)
 	if err != nil {
 		return ArtifactLister{}, err
 	}
@@ -39,27 +40,47 @@ func newArtifactLister(client bitriseAPIClient, logger log.Logger) ArtifactListe
 	}
 }
 
+// WithConcurrency returns a copy of the lister with the given number of list workers, and show workers per list worker
+// (the defaults are kept for the non-positive values)
+func (lister ArtifactLister) WithConcurrency(listWorkers, showWorkers int) ArtifactLister {
+	if listWorkers > 0 {
+		lister.maxConcurrentListArtifactAPICalls = listWorkers
+	}
+	if showWorkers > 0 {
+		lister.maxConcurrentShowArtifactAPICalls = showWorkers
+	}
+
+	return lister
+}
+
+// ListBuildArtifactDetails returns the details of the artifacts of the builds, in the order of the builds, and within a build ordered by title
 func (lister ArtifactLister) ListBuildArtifactDetails(appSlug string, buildSlugs []string) ([]ArtifactResponseItemModel, error) {
-	listJobs := make(chan string, len(buildSlugs))
+	listJobs := make(chan listArtifactsJob, len(buildSlugs))
 	listResults := make(chan listArtifactsResult, len(buildSlugs))
 
 	for w := 1; w <= lister.maxConcurrentListArtifactAPICalls; w++ {
 		go lister.listArtifactsWorker(appSlug, listJobs, listResults)
 	}
 
-	for _, buildSlug := range buildSlugs {
-		listJobs <- buildSlug
+	for i, buildSlug := range buildSlugs {
+		listJobs <- listArtifactsJob{index: i, buildSlug: buildSlug}
 	}
 	close(listJobs)
 
-	// process results
+	// results arrive in completion order, store them by the index of the build
+	results := make([]listArtifactsResult, len(buildSlugs))
+	for i := 1; i <= len(buildSlugs); i++ {
+		res := <-listResults
+		results[res.index] = res
+	}
+
 	var (
 		failedBuildSlugs []string
 		artifacts        []ArtifactResponseItemModel
 	)
-	for i := 1; i <= len(buildSlugs); i++ {
-		res := <-listResults
+	for _, res := range results {
 		if res.err != nil {
+			lister.logger.Warnf("Failed to get the artifacts of build %s: %s", res.buildSlug, res.err)
 			failedBuildSlugs = append(failedBuildSlugs, res.buildSlug)
 		} else {
 			artifacts = append(artifacts, res.artifacts...)
@@ -74,14 +95,15 @@ func (lister ArtifactLister) ListBuildArtifactDetails(appSlug string, buildSlugs
 }
 
 // listArtifactsWorker gets details of all artifacts of a particular build using the Bitrise API
-func (lister ArtifactLister) listArtifactsWorker(appSlug string, buildSlugs chan string, results chan listArtifactsResult) {
-	for buildSlug := range buildSlugs {
+func (lister ArtifactLister) listArtifactsWorker(appSlug string, jobs chan listArtifactsJob, results chan listArtifactsResult) {
+	for job := range jobs {
+		buildSlug := job.buildSlug
 		lister.logger.Debugf("Listing artifacts for build: https://app.bitrise.io/build/%v", buildSlug)
 		artifactListItems, err := lister.apiClient.ListBuildArtifacts(appSlug, buildSlug)
 		if err != nil {
-			results <- listArtifactsResult{buildSlug: buildSlug, err: err}
+			results <- listArtifactsResult{index: job.index, buildSlug: buildSlug, err: err}
 		} else if len(artifactListItems) == 0 {
-			results <- listArtifactsResult{buildSlug: buildSlug}
+			results <- listArtifactsResult{index: job.index, buildSlug: buildSlug}
 		} else {
 			showJobs := make(chan string, len(artifactListItems))
 			showResults := make(chan showArtifactResult, len(artifactListItems))
@@ -95,18 +117,26 @@ func (lister ArtifactLister) listArtifactsWorker(appSlug string, buildSlugs chan
 			}
 			close(showJobs)
 
-			var artifacts []ArtifactResponseItemModel
+			var (
+				artifacts []ArtifactResponseItemModel
+				showErr   error
+			)
 			for i := 0; i < len(artifactListItems); i++ {
 				res := <-showResults
 				if res.err != nil {
-					results <- listArtifactsResult{buildSlug: buildSlug, err: err}
-					return
+					showErr = res.err
 				} else {
 					artifacts = append(artifacts, res.artifact)
 				}
 			}
 
-			results <- listArtifactsResult{buildSlug: buildSlug, artifacts: artifacts}
+			if showErr != nil {
+				results <- listArtifactsResult{index: job.index, buildSlug: buildSlug, err: showErr}
+				continue
+			}
+
+			sortArtifacts(artifacts)
+			results <- listArtifactsResult{index: job.index, buildSlug: buildSlug, artifacts: artifacts}
 		}
 	}
 }
@@ -119,12 +149,29 @@ func (lister ArtifactLister) showArtifactWorker(appSlug, buildSlug string, artif
 		if err != nil {
 			results <- showArtifactResult{buildSlug: buildSlug, err: err}
 		} else {
+			artifact.BuildSlug = buildSlug
 			results <- showArtifactResult{buildSlug: buildSlug, artifact: artifact}
 		}
 	}
 }
 
+// sortArtifacts orders the artifacts of a build by title (and by slug, if the titles are the same)
+func sortArtifacts(artifacts []ArtifactResponseItemModel) {
+	sort.SliceStable(artifacts, func(i, j int) bool {
+		if artifacts[i].Title != artifacts[j].Title {
+			return artifacts[i].Title < artifacts[j].Title
+		}
+		return artifacts[i].Slug < artifacts[j].Slug
+	})
+}
+
+type listArtifactsJob struct {
+	index     int
+	buildSlug string
+}
+
 type listArtifactsResult struct {
+	index     int
 	buildSlug string
 	artifacts []ArtifactResponseItemModel
 	err       error
@@ -73,6 +73,9 @@ func Test_ListBuildArtifactDetails_concurrent_returnsArtifactListForMultipleBuil
 
 		assert.NoError(t, err)
 		assert.Equal(t, len(mockBuildSlugs)*len(mockArtifactList), len(artifacts))
+		for _, artifact := range artifacts {
+			assert.Equal(t, "build-slug", artifact.BuildSlug)
+		}
 	}
 }
 
@@ -89,3 +92,46 @@ func Test_ListBuildArtifactDetails_returnsErrorWhenApiCallFails(t *testing.T) {
 
 	assert.EqualError(t, err, "failed to get artifact download links for build(s): build-slug, build-slug, build-slug")
 }
+
+func Test_ListBuildArtifactDetails_returnsArtifactsInBuildAndTitleOrder(t *testing.T) {
+	mockClient := &mockBitriseAPIClient{}
+	for _, buildSlug := range []string{"build1", "build2", "build3"} {
+		mockClient.
+			On("ListBuildArtifacts", "app-slug", buildSlug).
+			Return([]ArtifactListElementResponseModel{{Slug: "b"}, {Slug: "c"}, {Slug: "a"}}, nil)
+		for _, artifactSlug := range []string{"a", "b", "c"} {
+			mockClient.
+				On("ShowBuildArtifact", "app-slug", buildSlug, artifactSlug).
+				Return(ArtifactResponseItemModel{Slug: artifactSlug, Title: artifactSlug + ".txt"}, nil)
+		}
+	}
+
+	lister := newArtifactLister(mockClient, log.NewLogger())
+	artifacts, err := lister.ListBuildArtifactDetails("app-slug", []string{"build3", "build1", "build2"})
+	assert.NoError(t, err)
+
+	var keys []string
+	for _, artifact := range artifacts {
+		keys = append(keys, artifact.BuildSlug+"/"+artifact.Title)
+	}
+	assert.Equal(t, []string{
+		"build3/a.txt", "build3/b.txt", "build3/c.txt",
+		"build1/a.txt", "build1/b.txt", "build1/c.txt",
+		"build2/a.txt", "build2/b.txt", "build2/c.txt",
+	}, keys)
+}
+
+func Test_ListBuildArtifactDetails_returnsErrorWhenShowCallFails(t *testing.T) {
+	mockClient := &mockBitriseAPIClient{}
+	mockClient.
+		On("ListBuildArtifacts", "app-slug", "build-slug").
+		Return([]ArtifactListElementResponseModel{{Slug: "artifact1"}}, nil)
+	mockClient.
+		On("ShowBuildArtifact", "app-slug", "build-slug", "artifact1").
+		Return(ArtifactResponseItemModel{}, errors.New("API error"))
+
+	lister := newArtifactLister(mockClient, log.NewLogger())
+	_, err := lister.ListBuildArtifactDetails("app-slug", []string{"build-slug"})
+
+	assert.EqualError(t, err, "failed to get artifact download links for build(s): build-slug")
+}
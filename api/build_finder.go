@@ -0,0 +1,64 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// BuildStatusSuccessful is the status code of a successfully finished build in the build list API
+const BuildStatusSuccessful = 1
+
+type BuildFilter struct {
+	Branch   string
+	Workflow string
+	Status   int
+	Limit    int
+}
+
+type bitriseBuildsAPIClient interface {
+	// ListBuilds lists the builds of an app - https://api-docs.bitrise.io/#/builds/build-list
+	ListBuilds(appSlug string, filter BuildFilter) ([]BuildListElementResponseModel, error)
+}
+
+type LatestBuildFinder struct {
+	apiClient bitriseBuildsAPIClient
+	logger    log.Logger
+}
+
+func NewLatestBuildFinder(apiBaseURL, authToken string, logger log.Logger, opts ...ClientOption) (LatestBuildFinder, error) {
+	client, err := NewDefaultBitriseAPIClient(apiBaseURL, authToken, opts...)
+	if err != nil {
+		return LatestBuildFinder{}, err
+	}
+
+	return newLatestBuildFinder(&client, logger), nil
+}
+
+func newLatestBuildFinder(client bitriseBuildsAPIClient, logger log.Logger) LatestBuildFinder {
+	return LatestBuildFinder{
+		apiClient: client,
+		logger:    logger,
+	}
+}
+
+// FindLatestSuccessfulBuild returns the most recent successful build of the app on the given branch and workflow (an empty value matches any)
+func (f LatestBuildFinder) FindLatestSuccessfulBuild(appSlug, branch, workflow string) (BuildListElementResponseModel, error) {
+	f.logger.Debugf("Searching for the latest successful build (branch: %q, workflow: %q)", branch, workflow)
+
+	builds, err := f.apiClient.ListBuilds(appSlug, BuildFilter{
+		Branch:   branch,
+		Workflow: workflow,
+		Status:   BuildStatusSuccessful,
+		Limit:    1,
+	})
+	if err != nil {
+		return BuildListElementResponseModel{}, fmt.Errorf("failed to list builds: %w", err)
+	}
+
+	if len(builds) == 0 {
+		return BuildListElementResponseModel{}, fmt.Errorf("no successful build found (branch: %q, workflow: %q)", branch, workflow)
+	}
+
+	return builds[0], nil
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockBitriseBuildsAPIClient struct {
+	mock.Mock
+}
+
+func (m *mockBitriseBuildsAPIClient) ListBuilds(appSlug string, filter BuildFilter) ([]BuildListElementResponseModel, error) {
+	args := m.Called(appSlug, filter)
+
+	r0, ok := args.Get(0).([]BuildListElementResponseModel)
+	if !ok {
+		panic("Type mismatch")
+	}
+	r1 := args.Error(1)
+
+	return r0, r1
+}
+
+func Test_FindLatestSuccessfulBuild_returnsNewestBuild(t *testing.T) {
+	expectedFilter := BuildFilter{Branch: "main", Workflow: "build-release", Status: BuildStatusSuccessful, Limit: 1}
+	builds := []BuildListElementResponseModel{{Slug: "build-2", BuildNumber: 2}, {Slug: "build-1", BuildNumber: 1}}
+
+	mockClient := &mockBitriseBuildsAPIClient{}
+	mockClient.On("ListBuilds", "app-slug", expectedFilter).Return(builds, nil)
+
+	finder := newLatestBuildFinder(mockClient, log.NewLogger())
+	build, err := finder.FindLatestSuccessfulBuild("app-slug", "main", "build-release")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "build-2", build.Slug)
+	mockClient.AssertExpectations(t)
+}
+
+func Test_FindLatestSuccessfulBuild_noMatchingBuild(t *testing.T) {
+	mockClient := &mockBitriseBuildsAPIClient{}
+	mockClient.On("ListBuilds", "app-slug", mock.Anything).Return([]BuildListElementResponseModel{}, nil)
+
+	finder := newLatestBuildFinder(mockClient, log.NewLogger())
+	_, err := finder.FindLatestSuccessfulBuild("app-slug", "main", "")
+
+	assert.EqualError(t, err, `no successful build found (branch: "main", workflow: "")`)
+}
+
+func Test_FindLatestSuccessfulBuild_apiError(t *testing.T) {
+	mockClient := &mockBitriseBuildsAPIClient{}
+	mockClient.On("ListBuilds", "app-slug", mock.Anything).Return([]BuildListElementResponseModel{}, errors.New("API error"))
+
+	finder := newLatestBuildFinder(mockClient, log.NewLogger())
+	_, err := finder.FindLatestSuccessfulBuild("app-slug", "main", "")
+
+	assert.EqualError(t, err, "failed to list builds: API error")
+}
@@ -1,58 +1,106 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/bitrise-io/go-utils/retry"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/redact"
 )
 
+const defaultTimeout = 30 * time.Second
+
 type DefaultBitriseAPIClient struct {
 	httpClient *http.Client
 	authToken  string
 	baseURL    string
+	ctx        context.Context
+}
+
+// ClientOption customizes the DefaultBitriseAPIClient
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	transport http.RoundTripper
+	timeout   time.Duration
+	ctx       context.Context
+}
+
+// WithTransport sets the transport of the API requests (each retry attempt is a separate round trip)
+func WithTransport(transport http.RoundTripper) ClientOption {
+	return func(o *clientOptions) {
+		o.transport = transport
+	}
+}
+
+// WithTimeout sets the timeout of the API requests, including the retries (the default is kept if it is not positive)
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		if timeout > 0 {
+			o.timeout = timeout
+		}
+	}
 }
 
-func NewDefaultBitriseAPIClient(baseURL, authToken string) (DefaultBitriseAPIClient, error) {
-	httpClient := retry.NewHTTPClient().StandardClient()
-	httpClient.Timeout = time.Second * 30
+// WithContext sets the context of the API requests, the requests are aborted once it is done (like at a step deadline)
+func WithContext(ctx context.Context) ClientOption {
+	return func(o *clientOptions) {
+		o.ctx = ctx
+	}
+}
+
+func NewDefaultBitriseAPIClient(baseURL, authToken string, opts ...ClientOption) (DefaultBitriseAPIClient, error) {
+	options := clientOptions{timeout: defaultTimeout, ctx: context.Background()}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	retryClient := retry.NewHTTPClient()
+	if options.transport != nil {
+		retryClient.HTTPClient.Transport = options.transport
+	}
+	httpClient := retryClient.StandardClient()
+	httpClient.Timeout = options.timeout
 
 	c := DefaultBitriseAPIClient{
 		httpClient: httpClient,
 		authToken:  authToken,
 		baseURL:    baseURL,
+		ctx:        options.ctx,
 	}
 
 	return c, nil
 }
 
-func (c DefaultBitriseAPIClient) get(endpoint, next string) (*http.Response, error) {
+func (c DefaultBitriseAPIClient) get(endpoint string, query url.Values) (*http.Response, error) {
 	url := fmt.Sprintf("%s/%s", c.baseURL, endpoint)
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(c.ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Add("Authorization", "Bearer "+c.authToken)
 
-	if next != "" {
-		queryValues := req.URL.Query()
-		queryValues.Add("next", next)
-		req.URL.RawQuery = queryValues.Encode()
+	if len(query) > 0 {
+		req.URL.RawQuery = query.Encode()
 	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		// the transport errors may contain the request URL or the headers
+		return nil, redact.New(c.authToken).Error(err)
 	}
 
 	if resp.StatusCode >= 300 || resp.StatusCode < 200 {
-		err = fmt.Errorf("request to %s failed - status code should be 2XX (%d)", req.URL.String(), resp.StatusCode)
+		err = fmt.Errorf("request to %s failed - status code should be 2XX (%d)", redact.New(c.authToken).String(req.URL.String()), resp.StatusCode)
 	}
 
 	return resp, err
@@ -65,7 +113,12 @@ func (c *DefaultBitriseAPIClient) ListBuildArtifacts(appSlug, buildSlug string)
 
 	var next string
 	for {
-		resp, err := c.get(requestPath, next)
+		query := url.Values{}
+		if next != "" {
+			query.Set("next", next)
+		}
+
+		resp, err := c.get(requestPath, query)
 		if err != nil {
 			return nil, err
 		}
@@ -97,7 +150,7 @@ func (c *DefaultBitriseAPIClient) ListBuildArtifacts(appSlug, buildSlug string)
 func (c *DefaultBitriseAPIClient) ShowBuildArtifact(appSlug, buildSlug, artifactSlug string) (ArtifactResponseItemModel, error) {
 	requestPath := fmt.Sprintf("v0.2/apps/%s/builds/%s/artifacts/%s", appSlug, buildSlug, artifactSlug)
 
-	resp, err := c.get(requestPath, "") //nolint: bodyclose
+	resp, err := c.get(requestPath, nil) //nolint: bodyclose
 	if err != nil {
 		return ArtifactResponseItemModel{}, err
 	}
@@ -117,6 +170,43 @@ func (c *DefaultBitriseAPIClient) ShowBuildArtifact(appSlug, buildSlug, artifact
 	return responseModel.Data, nil
 }
 
+// ListBuilds gets the first page of builds of an app matching the given filter, newest first
+func (c *DefaultBitriseAPIClient) ListBuilds(appSlug string, filter BuildFilter) ([]BuildListElementResponseModel, error) {
+	requestPath := fmt.Sprintf("v0.1/apps/%s/builds", appSlug)
+
+	query := url.Values{}
+	query.Set("sort_by", "created_at")
+	query.Set("status", strconv.Itoa(filter.Status))
+	if filter.Branch != "" {
+		query.Set("branch", filter.Branch)
+	}
+	if filter.Workflow != "" {
+		query.Set("workflow", filter.Workflow)
+	}
+	if filter.Limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+
+	resp, err := c.get(requestPath, query) //nolint: bodyclose
+	if err != nil {
+		return nil, err
+	}
+	defer responseBodyCloser(resp)
+
+	var respBody []byte
+	respBody, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var responseModel ListBuildsResponse
+	if err := json.Unmarshal(respBody, &responseModel); err != nil {
+		return nil, err
+	}
+
+	return responseModel.Data, nil
+}
+
 func responseBodyCloser(resp *http.Response) {
 	if err := resp.Body.Close(); err != nil {
 		log.Printf(" [!] Failed to close response body: %+v", err)
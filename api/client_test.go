@@ -123,3 +123,30 @@ func Test_ListBuildArtifacts_unauthorizedError(t *testing.T) {
 	assert.Nil(t, artifactList)
 	assert.EqualError(t, showErr, fmt.Sprintf("request to %s/v0.2/apps/app-slug/builds/build-slug/artifacts failed - status code should be 2XX (401)", svr.URL))
 }
+
+func Test_ListBuilds_sendsFilterAndReturnsBuilds(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v0.1/apps/app-slug/builds", r.URL.Path)
+		assert.Equal(t, "main", r.URL.Query().Get("branch"))
+		assert.Equal(t, "build-release", r.URL.Query().Get("workflow"))
+		assert.Equal(t, "1", r.URL.Query().Get("status"))
+		assert.Equal(t, "1", r.URL.Query().Get("limit"))
+		assert.Equal(t, "created_at", r.URL.Query().Get("sort_by"))
+
+		response := `{"data":[{"slug":"build-slug","build_number":42,"branch":"main","triggered_workflow":"build-release","status":1}],"paging":{"total_item_count":1,"page_item_limit":1}}`
+		_, err := w.Write([]byte(response))
+		assert.NoError(t, err)
+	}))
+	defer svr.Close()
+
+	client, err := NewDefaultBitriseAPIClient(svr.URL, "token")
+	assert.NoError(t, err)
+
+	builds, listErr := client.ListBuilds("app-slug", BuildFilter{Branch: "main", Workflow: "build-release", Status: BuildStatusSuccessful, Limit: 1})
+
+	assert.NoError(t, listErr)
+	expectedBuilds := []BuildListElementResponseModel{
+		{Slug: "build-slug", BuildNumber: 42, Branch: "main", TriggeredWorkflow: "build-release", Status: 1},
+	}
+	assert.Equal(t, expectedBuilds, builds)
+}
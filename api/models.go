@@ -13,6 +13,10 @@ type ArtifactResponseItemModel struct {
 	Title       string `json:"title"`
 	DownloadURL string `json:"expiring_download_url"`
 	Slug        string `json:"slug"`
+	// FileSizeBytes is the size of the artifact file, 0 if it is not known
+	FileSizeBytes int64 `json:"file_size_bytes"`
+	// BuildSlug is the slug of the build which generated the artifact, it is set by the ArtifactLister
+	BuildSlug string `json:"-"`
 }
 
 type ArtifactListElementResponseModel struct {
@@ -20,6 +24,21 @@ type ArtifactListElementResponseModel struct {
 	Slug  string `json:"slug"`
 }
 
+type ListBuildsResponse struct {
+	Data   []BuildListElementResponseModel `json:"data"`
+	Paging PagingModel                     `json:"paging"`
+}
+
+type BuildListElementResponseModel struct {
+	Slug              string `json:"slug"`
+	BuildNumber       int64  `json:"build_number"`
+	Branch            string `json:"branch"`
+	TriggeredWorkflow string `json:"triggered_workflow"`
+	Status            int    `json:"status"`
+	StatusText        string `json:"status_text"`
+	FinishedAt        string `json:"finished_at"`
+}
+
 type PagingModel struct {
 	// TotalItemCount - total item count, through "all pages"
 	TotalItemCount int64 `json:"total_item_count"`
@@ -0,0 +1,128 @@
+package apirecord
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const bundleVersion = 1
+
+// bodiesDirSuffix is appended to the bundle path to get the directory of the recorded download bodies
+const bodiesDirSuffix = ".bodies"
+
+// Bundle holds the recorded HTTP interactions of a step run together with the finished stages it ran with
+type Bundle struct {
+	Version        int             `json:"version"`
+	CreatedAt      time.Time       `json:"created_at"`
+	FinishedStages json.RawMessage `json:"finished_stages,omitempty"`
+	Interactions   []Interaction   `json:"interactions"`
+
+	// bodiesDir is the directory of the body files of a loaded bundle
+	bodiesDir string
+
+	mu        sync.Mutex
+	bodyFiles int
+}
+
+// Interaction is a recorded request and its response (or transport error), with the secrets redacted
+type Interaction struct {
+	Method string `json:"method"`
+	// URL is the redacted request URL
+	URL    string      `json:"url"`
+	Status int         `json:"status,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	// Body is the response body if it is valid UTF-8, otherwise BodyBase64 holds it
+	Body       string `json:"body,omitempty"`
+	BodyBase64 string `json:"body_base64,omitempty"`
+	// BodyFile is the name of the file in the bodies directory holding the unmodified body, used for the downloads
+	BodyFile   string `json:"body_file,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// BodiesDir returns the directory of the download bodies recorded together with the bundle at the given path
+func BodiesDir(bundlePath string) string {
+	return bundlePath + bodiesDirSuffix
+}
+
+func NewBundle() *Bundle {
+	return &Bundle{Version: bundleVersion, CreatedAt: time.Now().UTC()}
+}
+
+func LoadBundle(pth string) (*Bundle, error) {
+	content, err := ioutil.ReadFile(pth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the API record bundle: %w", err)
+	}
+
+	var bundle Bundle
+	if err := json.Unmarshal(content, &bundle); err != nil {
+		return nil, fmt.Errorf("failed to parse the API record bundle: %w", err)
+	}
+	if bundle.Version != bundleVersion {
+		return nil, fmt.Errorf("unsupported API record bundle version: %d", bundle.Version)
+	}
+	bundle.bodiesDir = BodiesDir(pth)
+
+	return &bundle, nil
+}
+
+func (b *Bundle) Save(pth string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	content, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(pth, content, 0o600); err != nil {
+		return fmt.Errorf("failed to write the API record bundle: %w", err)
+	}
+
+	return nil
+}
+
+func (i *Interaction) setBody(body []byte) {
+	if utf8.Valid(body) {
+		i.Body = string(body)
+	} else {
+		i.BodyBase64 = base64.StdEncoding.EncodeToString(body)
+	}
+}
+
+func (i Interaction) body() ([]byte, error) {
+	if i.BodyBase64 != "" {
+		return base64.StdEncoding.DecodeString(i.BodyBase64)
+	}
+
+	return []byte(i.Body), nil
+}
+
+// nextBodyFile returns a new body file name, unique within the bundle
+func (b *Bundle) nextBodyFile() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.bodyFiles++
+
+	return fmt.Sprintf("%d.body", b.bodyFiles)
+}
+
+func (b *Bundle) bodyFilePath(name string) string {
+	return filepath.Join(b.bodiesDir, name)
+}
+
+func (b *Bundle) add(interaction Interaction) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.Interactions = append(b.Interactions, interaction)
+}
@@ -0,0 +1,198 @@
+package apirecord
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/redact"
+)
+
+// recordingTransport records the interactions of the underlying transport into a bundle
+type recordingTransport struct {
+	base      http.RoundTripper
+	bundle    *Bundle
+	redactor  redact.Redactor
+	bodiesDir string
+}
+
+// RecordingTransport returns a transport recording every request and response of the base transport (the default
+// transport if it is nil) into the bundle. The URLs, the response headers and the response bodies are redacted with
+// the redactor (the signed query strings and the secrets are masked). The bodies are stored in the bundle, unless bodiesDir
+// is set: then the bodies of the successful responses (like the artifact downloads) are streamed unmodified into files
+// of that directory (see BodiesDir), while the caller reads them.
+func (b *Bundle) RecordingTransport(base http.RoundTripper, redactor redact.Redactor, bodiesDir string) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return recordingTransport{base: base, bundle: b, redactor: redactor, bodiesDir: bodiesDir}
+}
+
+func (t recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+
+	interaction := Interaction{
+		Method: req.Method,
+		URL:    t.redactor.String(req.URL.String()),
+	}
+	if err != nil {
+		interaction.Error = t.redactor.String(err.Error())
+		interaction.DurationMS = time.Since(start).Milliseconds()
+		t.bundle.add(interaction)
+		return nil, err
+	}
+
+	interaction.Status = resp.StatusCode
+	interaction.Header = t.redactor.Header(resp.Header)
+
+	if t.bodiesDir != "" && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		// the duration only covers the response headers, the body is read later by the caller
+		interaction.BodyFile = t.bundle.nextBodyFile()
+		if err := t.recordBodyToFile(resp, interaction.BodyFile); err != nil {
+			interaction.Error = fmt.Sprintf("failed to record the response body: %s", err)
+		}
+	} else {
+		body, readErr := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if readErr != nil {
+			interaction.Error = readErr.Error()
+		}
+		interaction.setBody([]byte(t.redactor.String(string(body))))
+	}
+
+	interaction.DurationMS = time.Since(start).Milliseconds()
+	t.bundle.add(interaction)
+
+	return resp, nil
+}
+
+// recordBodyToFile replaces the response body with a reader copying the body into the body file as it is read
+func (t recordingTransport) recordBodyToFile(resp *http.Response, name string) error {
+	if err := os.MkdirAll(t.bodiesDir, 0o700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(t.bodiesDir, name), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	resp.Body = teeBody{Reader: io.TeeReader(resp.Body, file), body: resp.Body, file: file}
+
+	return nil
+}
+
+// teeBody is a response body which writes the read content into a file
+type teeBody struct {
+	io.Reader
+	body io.Closer
+	file *os.File
+}
+
+func (b teeBody) Close() error {
+	fileErr := b.file.Close()
+	if err := b.body.Close(); err != nil {
+		return err
+	}
+
+	return fileErr
+}
+
+// replayTransport serves the recorded responses of a bundle
+type replayTransport struct {
+	bundle   *Bundle
+	redactor redact.Redactor
+
+	mu     sync.Mutex
+	queues map[string][]int
+}
+
+// ReplayTransport returns a transport responding to each request with the next recorded response of the same request,
+// without network access. The requests are matched by method, path and redacted query.
+func (b *Bundle) ReplayTransport() http.RoundTripper {
+	queues := make(map[string][]int)
+	for i, interaction := range b.Interactions {
+		key := requestKey(interaction.Method, interaction.URL)
+		queues[key] = append(queues[key], i)
+	}
+
+	return &replayTransport{bundle: b, redactor: redact.New(), queues: queues}
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+
+	key := requestKey(req.Method, t.redactor.String(req.URL.String()))
+
+	t.mu.Lock()
+	queue := t.queues[key]
+	if len(queue) == 0 {
+		t.mu.Unlock()
+		return nil, fmt.Errorf("no recorded response for %s", key)
+	}
+	t.queues[key] = queue[1:]
+	t.mu.Unlock()
+
+	interaction := t.bundle.Interactions[queue[0]]
+	if interaction.Status == 0 {
+		return nil, errors.New(interaction.Error)
+	}
+
+	resp := &http.Response{
+		Status:     fmt.Sprintf("%d %s", interaction.Status, http.StatusText(interaction.Status)),
+		StatusCode: interaction.Status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     interaction.Header.Clone(),
+		Request:    req,
+	}
+
+	if interaction.BodyFile != "" {
+		file, err := os.Open(t.bundle.bodyFilePath(interaction.BodyFile))
+		if err != nil {
+			return nil, fmt.Errorf("invalid recorded response body for %s: %w", key, err)
+		}
+		info, err := file.Stat()
+		if err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("invalid recorded response body for %s: %w", key, err)
+		}
+
+		resp.Body = file
+		resp.ContentLength = info.Size()
+
+		return resp, nil
+	}
+
+	body, err := interaction.body()
+	if err != nil {
+		return nil, fmt.Errorf("invalid recorded response body for %s: %w", key, err)
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+
+	return resp, nil
+}
+
+// requestKey identifies a request in the bundle: the method and the redacted URL without the scheme and host, so
+// that a bundle can be replayed with a different API base URL
+func requestKey(method, redactedURL string) string {
+	if u, err := url.Parse(redactedURL); err == nil {
+		return method + " " + u.RequestURI()
+	}
+
+	return method + " " + redactedURL
+}
@@ -0,0 +1,135 @@
+package apirecord
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/api"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/fakeapi"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/redact"
+	"github.com/stretchr/testify/assert"
+)
+
+func fakeSpec() fakeapi.Spec {
+	return fakeapi.Spec{
+		Token:    "secret-token",
+		PageSize: 1,
+		Apps: map[string]fakeapi.App{
+			"app-slug": {Builds: []fakeapi.Build{
+				{Slug: "build-1", Status: 1, Artifacts: []fakeapi.Artifact{
+					{Slug: "artifact-1", Title: "app.apk", Content: []byte("apk")},
+					{Slug: "artifact-2", Title: "app.ipa", Content: []byte("ipa")},
+				}},
+			}},
+		},
+	}
+}
+
+type session struct {
+	artifacts []api.ArtifactListElementResponseModel
+	shown     api.ArtifactResponseItemModel
+	status    int
+	body      string
+}
+
+func runSession(t *testing.T, baseURL string, transport, downloadTransport http.RoundTripper) session {
+	client, err := api.NewDefaultBitriseAPIClient(baseURL, "secret-token", api.WithTransport(transport))
+	assert.NoError(t, err)
+
+	artifacts, err := client.ListBuildArtifacts("app-slug", "build-1")
+	assert.NoError(t, err)
+	shown, err := client.ShowBuildArtifact("app-slug", "build-1", "artifact-2")
+	assert.NoError(t, err)
+
+	resp, err := (&http.Client{Transport: downloadTransport}).Get(shown.DownloadURL)
+	assert.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.NoError(t, resp.Body.Close())
+
+	return session{artifacts: artifacts, shown: shown, status: resp.StatusCode, body: string(body)}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	server := fakeapi.NewServer(fakeSpec())
+	baseURL := server.Start()
+	defer server.Close()
+
+	pth := filepath.Join(t.TempDir(), "bundle.json")
+	bundle := NewBundle()
+	redactor := redact.New("secret-token")
+	recorded := runSession(t, baseURL, bundle.RecordingTransport(nil, redactor, ""), bundle.RecordingTransport(nil, redactor, BodiesDir(pth)))
+	assert.NoError(t, bundle.Save(pth))
+
+	content, err := ioutil.ReadFile(pth)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(content), "secret-token")
+	assert.NotRegexp(t, "signature=[0-9a-f]+", string(content))
+	assert.Len(t, bundle.Interactions, 4)
+	// the downloaded content is stored unmodified in a body file, outside of the bundle
+	assert.Equal(t, "", bundle.Interactions[3].Body)
+	downloaded, err := ioutil.ReadFile(filepath.Join(BodiesDir(pth), bundle.Interactions[3].BodyFile))
+	assert.NoError(t, err)
+	assert.Equal(t, "ipa", string(downloaded))
+
+	loaded, err := LoadBundle(pth)
+	assert.NoError(t, err)
+	replay := loaded.ReplayTransport()
+	// the fake server is not used during the replay
+	replayed := runSession(t, "http://replay.invalid", replay, replay)
+
+	assert.Equal(t, recorded.artifacts, replayed.artifacts)
+	assert.Equal(t, recorded.shown.Title, replayed.shown.Title)
+	assert.Equal(t, recorded.status, replayed.status)
+	assert.Equal(t, "ipa", replayed.body)
+	assert.True(t, strings.HasSuffix(replayed.shown.DownloadURL, "?[REDACTED]"))
+	assert.Equal(t, 4, server.Requests(fakeapi.EndpointAny))
+
+	_, err = replay.RoundTrip(mustRequest(t, "http://replay.invalid/v0.2/apps/app-slug/builds/build-2/artifacts"))
+	assert.EqualError(t, err, "no recorded response for GET /v0.2/apps/app-slug/builds/build-2/artifacts")
+}
+
+func mustRequest(t *testing.T, url string) *http.Request {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	assert.NoError(t, err)
+
+	return req
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRecordingTransport_RedactsResponses(t *testing.T) {
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		header := http.Header{}
+		header.Set("Location", "https://storage.example.com/a.apk?signature=abc")
+		header.Set("Set-Cookie", "session=abc")
+
+		return &http.Response{
+			StatusCode: http.StatusFound,
+			Header:     header,
+			Body:       ioutil.NopCloser(strings.NewReader(`{"token":"secret-token","url":"https://storage.example.com/a.apk?expires=1\u0026signature=abc"}`)),
+			Request:    req,
+		}, nil
+	})
+
+	bundle := NewBundle()
+	transport := bundle.RecordingTransport(base, redact.New("secret-token"), "")
+	resp, err := transport.RoundTrip(mustRequest(t, "https://api.bitrise.io/v0.2/apps/a/builds/b/artifacts?next=slug-2"))
+	assert.NoError(t, err)
+	assert.NoError(t, resp.Body.Close())
+
+	interaction := bundle.Interactions[0]
+	assert.Equal(t, "https://api.bitrise.io/v0.2/apps/a/builds/b/artifacts?next=slug-2", interaction.URL)
+	assert.Equal(t, "https://storage.example.com/a.apk?[REDACTED]", interaction.Header.Get("Location"))
+	assert.Equal(t, "[REDACTED]", interaction.Header.Get("Set-Cookie"))
+	assert.Equal(t, `{"token":"[REDACTED]","url":"https://storage.example.com/a.apk?[REDACTED]"}`, interaction.Body)
+	// the caller gets the original response
+	assert.Equal(t, "session=abc", resp.Header.Get("Set-Cookie"))
+}
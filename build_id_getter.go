@@ -1,78 +1,163 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
 	"github.com/bitrise-steplib/bitrise-step-artifact-pull/model"
-	"regexp"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/selector"
 )
 
-const DELIMITER = "."
-
 type BuildIDGetter struct {
-	FinishedStages model.FinishedStages
-	TargetNames    []string
-}
-
-type keyValuePair struct {
-	key   string
-	value string
+	FinishedStages         model.FinishedStages
+	Filter                 selector.Filter
+	FailOnUnmatchedSources bool
+	// LatestRunOnly keeps only the latest run of a workflow if it ran multiple times in a stage
+	LatestRunOnly bool
+	// FinishedAfter drops the workflows which finished before this time, unless it is zero
+	FinishedAfter time.Time
+	Logger        log.Logger
 }
 
-func NewBuildIDGetter(finishedStages model.FinishedStages, targetNames []string) BuildIDGetter {
+func NewBuildIDGetter(finishedStages model.FinishedStages, filter selector.Filter, failOnUnmatchedSources bool, logger log.Logger) BuildIDGetter {
 	return BuildIDGetter{
-		FinishedStages: finishedStages,
-		TargetNames:    targetNames,
+		FinishedStages:         finishedStages,
+		Filter:                 filter,
+		FailOnUnmatchedSources: failOnUnmatchedSources,
+		Logger:                 logger,
 	}
 }
 
+// GetBuildIDs returns the IDs of the selected builds in stage order, and within a stage in workflow order
 func (bg BuildIDGetter) GetBuildIDs() ([]string, error) {
+	var buildIDs []string
 	buildIDsSet := make(map[string]bool)
+	matchCounts := make(map[selector.Selector]int)
+	var keys []string
 
-	kvpSlice := bg.createKeyValuePairSlice()
+	for _, stage := range bg.FinishedStages {
+		for _, wf := range bg.recentWorkflows(stage) {
+			keys = append(keys, stage.Name+selector.LegacyDelimiter+wf.Name)
+			bg.countMatches(matchCounts, stage.Name, wf.Name)
 
-	if len(bg.TargetNames) == 0 {
-		for _, kvPair := range kvpSlice {
-			buildIDsSet[kvPair.value] = true
+			if bg.isSelected(stage.Name, wf.Name) && !buildIDsSet[wf.ExternalId] {
+				buildIDsSet[wf.ExternalId] = true
+				buildIDs = append(buildIDs, wf.ExternalId)
+			}
 		}
+	}
 
-		return convertKeySetToArray(buildIDsSet), nil
+	if err := bg.checkMatches(matchCounts, keys, len(buildIDsSet)); err != nil {
+		return nil, err
 	}
 
-	for _, target := range bg.TargetNames {
-		for _, kvPair := range kvpSlice {
-			matched, err := regexp.MatchString(target, kvPair.key)
-			if err != nil {
-				return nil, err
+	return buildIDs, nil
+}
+
+// recentWorkflows returns the workflows of the stage which pass the FinishedAfter and LatestRunOnly filters
+func (bg BuildIDGetter) recentWorkflows(stage model.Stage) []model.Workflow {
+	var workflows []model.Workflow
+	latestRunIndexes := make(map[string]int)
+
+	for _, wf := range stage.Workflows {
+		key := stage.Name + selector.LegacyDelimiter + wf.Name
+
+		if !bg.FinishedAfter.IsZero() {
+			if wf.FinishedAt == nil {
+				bg.Logger.Warnf("%s (build %s) is skipped, its finish time is unknown", key, wf.ExternalId)
+				continue
+			}
+			if wf.FinishedAt.Before(bg.FinishedAfter) {
+				bg.Logger.Debugf("%s (build %s) is skipped, it finished before %s", key, wf.ExternalId, bg.FinishedAfter.Format(time.RFC3339))
+				continue
 			}
+		}
 
-			if matched {
-				buildIDsSet[kvPair.value] = true
+		if bg.LatestRunOnly {
+			if i, ok := latestRunIndexes[wf.Name]; ok {
+				older := wf
+				if wf.IsNewerRunThan(workflows[i]) {
+					older, workflows[i] = workflows[i], wf
+				}
+				bg.Logger.Debugf("%s (build %s) is skipped, it is not the latest run of the workflow", key, older.ExternalId)
+				continue
 			}
+			latestRunIndexes[wf.Name] = len(workflows)
 		}
+
+		workflows = append(workflows, wf)
 	}
 
-	return convertKeySetToArray(buildIDsSet), nil
+	return workflows
+}
+
+func (bg BuildIDGetter) countMatches(matchCounts map[selector.Selector]int, stage, workflow string) {
+	for _, s := range append(append([]selector.Selector{}, bg.Filter.Include...), bg.Filter.Exclude...) {
+		if s.Match(stage, workflow) {
+			matchCounts[s]++
+		}
+	}
 }
 
-func convertKeySetToArray(set map[string]bool) []string {
-	var ids []string
+// checkMatches reports the artifact source patterns which did not match any workflow,
+// as a warning or as an error if FailOnUnmatchedSources is set.
+// Empty patterns match everything, so they are not reported on their own.
+func (bg BuildIDGetter) checkMatches(matchCounts map[selector.Selector]int, keys []string, selectedCount int) error {
+	var unmatched []string
+	for _, s := range bg.Filter.Include {
+		if matchCounts[s] == 0 && s.String() != "" {
+			unmatched = append(unmatched, s.String())
+		}
+	}
+	for _, s := range bg.Filter.Exclude {
+		if matchCounts[s] == 0 {
+			unmatched = append(unmatched, "!"+s.String())
+		}
+	}
 
-	for k := range set {
-		ids = append(ids, k)
+	if len(unmatched) == 0 && selectedCount > 0 {
+		return nil
 	}
 
-	return ids
+	available := "(none)"
+	if len(keys) > 0 {
+		available = strings.Join(keys, ", ")
+	}
+
+	var msg string
+	if len(keys) == 0 {
+		msg = "no source workflows were found"
+	} else if len(unmatched) > 0 {
+		msg = fmt.Sprintf("the following artifact source patterns did not match any workflow: %s (available stage.workflow keys: %s)", strings.Join(unmatched, ", "), available)
+	} else {
+		msg = fmt.Sprintf("none of the workflows is selected as an artifact source (available stage.workflow keys: %s)", available)
+	}
+
+	if bg.FailOnUnmatchedSources {
+		return errors.New(msg)
+	}
+	bg.Logger.Warnf("Warning: %s", msg)
+
+	return nil
 }
 
-func (bg BuildIDGetter) createKeyValuePairSlice() []keyValuePair {
-	var stageWorkflowMap []keyValuePair
-	for _, stage := range bg.FinishedStages {
-		for _, wf := range stage.Workflows {
-			stageWorkflowMap = append(stageWorkflowMap, keyValuePair{
-				key:   stage.Name + DELIMITER + wf.Name,
-				value: wf.ExternalId,
-			})
-		}
+func (bg BuildIDGetter) isSelected(stage, workflow string) bool {
+	selected, rule := bg.Filter.Evaluate(stage, workflow)
+
+	key := stage + selector.LegacyDelimiter + workflow
+	switch {
+	case selected && rule != nil:
+		bg.Logger.Debugf("%s is included by rule: %s", key, rule)
+	case selected:
+		bg.Logger.Debugf("%s is included, no include rule is defined", key)
+	case rule != nil:
+		bg.Logger.Debugf("%s is excluded by rule: %s", key, rule)
+	default:
+		bg.Logger.Debugf("%s is not matched by any include rule", key)
 	}
 
-	return stageWorkflowMap
+	return selected
 }
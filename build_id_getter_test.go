@@ -1,10 +1,14 @@
 package main
 
 import (
+	"encoding/json"
 	"sort"
 	"testing"
+	"time"
 
+	"github.com/bitrise-io/go-utils/log"
 	"github.com/bitrise-steplib/bitrise-step-artifact-pull/model"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/selector"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -114,7 +118,10 @@ func Test_GetBuildIDs_without_wildcards(t *testing.T) {
 	}
 	for _, tC := range testCases {
 		t.Run(tC.desc, func(t *testing.T) {
-			buildIDGetter := NewBuildIDGetter(tC.finishedStages, tC.targetNames)
+			selectors, err := selector.ParseLegacy(tC.targetNames)
+			assert.NoError(t, err)
+
+			buildIDGetter := NewBuildIDGetter(tC.finishedStages, selector.Filter{Include: selectors}, false, log.NewLogger())
 
 			buildIDs, err := buildIDGetter.GetBuildIDs()
 			if tC.expectedErrorMessage != "" {
@@ -130,3 +137,218 @@ func Test_GetBuildIDs_without_wildcards(t *testing.T) {
 		})
 	}
 }
+
+func Test_GetBuildIDs_structured_selectors(t *testing.T) {
+	finishedStages := model.FinishedStages{
+		{
+			Name: "stage1.wf",
+			Workflows: []model.Workflow{
+				{Name: "deploy", ExternalId: "build1"},
+			},
+		},
+		{
+			Name: "stage10",
+			Workflows: []model.Workflow{
+				{Name: "wf-old", ExternalId: "build2"},
+				{Name: "ui-flaky", ExternalId: "build3"},
+			},
+		},
+	}
+
+	selectors, err := selector.ParseStructured("stage=stage1.wf;stage=stage1*,workflow=!*-flaky")
+	assert.NoError(t, err)
+
+	buildIDs, err := NewBuildIDGetter(finishedStages, selector.Filter{Include: selectors}, false, log.NewLogger()).GetBuildIDs()
+	assert.NoError(t, err)
+
+	sort.Strings(buildIDs)
+	assert.Equal(t, []string{"build1", "build2"}, buildIDs)
+}
+
+func Test_GetBuildIDs_exclusions(t *testing.T) {
+	finishedStages := model.FinishedStages{
+		{
+			Name: "stage1",
+			Workflows: []model.Workflow{
+				{Name: "build", ExternalId: "build1"},
+				{Name: "nightly-ui", ExternalId: "build2"},
+			},
+		},
+		{
+			Name: "stage2",
+			Workflows: []model.Workflow{
+				{Name: "nightly-unit", ExternalId: "build3"},
+				{Name: "deploy", ExternalId: "build4"},
+			},
+		},
+	}
+
+	filter, err := selector.ParseFilter("!stage2\\.deploy", ".*nightly.*", selector.SyntaxLegacy)
+	assert.NoError(t, err)
+
+	buildIDs, err := NewBuildIDGetter(finishedStages, filter, false, log.NewLogger()).GetBuildIDs()
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"build1"}, buildIDs)
+}
+
+func Test_GetBuildIDs_unmatched_sources(t *testing.T) {
+	finishedStages := model.FinishedStages{
+		{
+			Name: "stage1",
+			Workflows: []model.Workflow{
+				{Name: "build", ExternalId: "build1"},
+				{Name: "test", ExternalId: "build2"},
+			},
+		},
+	}
+
+	testCases := []struct {
+		desc                 string
+		include, exclude     string
+		failOnUnmatched      bool
+		expectedBuildIDs     []string
+		expectedErrorMessage string
+	}{
+		{
+			desc:             "when a pattern does not match and failing is disabled, it returns the matched build IDs",
+			include:          "stage1\\.build,stage1\\.biuld",
+			failOnUnmatched:  false,
+			expectedBuildIDs: []string{"build1"},
+		},
+		{
+			desc:                 "when a pattern does not match, it fails",
+			include:              "stage1\\.build,stage1\\.biuld",
+			failOnUnmatched:      true,
+			expectedErrorMessage: "the following artifact source patterns did not match any workflow: stage1\\.biuld (available stage.workflow keys: stage1.build, stage1.test)",
+		},
+		{
+			desc:                 "when an exclude pattern does not match, it fails",
+			include:              ".*",
+			exclude:              ".*nightly",
+			failOnUnmatched:      true,
+			expectedErrorMessage: "the following artifact source patterns did not match any workflow: !.*nightly (available stage.workflow keys: stage1.build, stage1.test)",
+		},
+		{
+			desc:                 "when every workflow is excluded, it fails",
+			include:              ".*",
+			exclude:              "stage1\\..*",
+			failOnUnmatched:      true,
+			expectedErrorMessage: "none of the workflows is selected as an artifact source (available stage.workflow keys: stage1.build, stage1.test)",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			filter, err := selector.ParseFilter(tC.include, tC.exclude, selector.SyntaxLegacy)
+			assert.NoError(t, err)
+
+			buildIDs, err := NewBuildIDGetter(finishedStages, filter, tC.failOnUnmatched, log.NewLogger()).GetBuildIDs()
+			if tC.expectedErrorMessage != "" {
+				assert.EqualError(t, err, tC.expectedErrorMessage)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, tC.expectedBuildIDs, buildIDs)
+		})
+	}
+}
+
+func Test_GetBuildIDs_no_source_workflows(t *testing.T) {
+	selectors, err := selector.ParseLegacy([]string{""})
+	assert.NoError(t, err)
+
+	for _, filter := range []selector.Filter{{}, {Include: selectors}} {
+		buildIDs, err := NewBuildIDGetter(model.FinishedStages{}, filter, true, log.NewLogger()).GetBuildIDs()
+
+		assert.EqualError(t, err, "no source workflows were found")
+		assert.Empty(t, buildIDs)
+	}
+}
+
+func Test_GetBuildIDs_recency_filters(t *testing.T) {
+	finishedStagesJSON := `[{
+		"name": "stage1",
+		"workflows": [
+			{"external_id": "build1a", "name": "build", "started_at": "2022-05-24T20:00:00Z", "finished_at": "2022-05-24T20:10:00Z"},
+			{"external_id": "build1b", "name": "build", "started_at": "2022-05-24T20:20:00Z", "finished_at": "2022-05-24T20:30:00Z"},
+			{"external_id": "build2", "name": "test", "started_at": "2022-05-24T18:00:00Z", "finished_at": "2022-05-24T18:30:00Z"}
+		]
+	}, {
+		"name": "stage2",
+		"workflows": [
+			{"external_id": "build3", "name": "build", "started_at": "2022-05-24T21:00:00Z", "finished_at": "2022-05-24T21:10:00Z"},
+			{"external_id": "build4", "name": "deploy", "started_at": "", "finished_at": ""}
+		]
+	}]`
+	var finishedStages model.FinishedStages
+	assert.NoError(t, json.Unmarshal([]byte(finishedStagesJSON), &finishedStages))
+
+	testCases := []struct {
+		desc             string
+		latestRunOnly    bool
+		finishedAfter    time.Time
+		expectedBuildIDs []string
+	}{
+		{
+			desc:             "when no recency filter is set, it returns every run",
+			expectedBuildIDs: []string{"build1a", "build1b", "build2", "build3", "build4"},
+		},
+		{
+			desc:             "when only the latest runs are needed, it keeps the latest run per stage and workflow",
+			latestRunOnly:    true,
+			expectedBuildIDs: []string{"build1b", "build2", "build3", "build4"},
+		},
+		{
+			desc:             "when a time window is set, it drops the workflows finished before it or with an unknown finish time",
+			finishedAfter:    time.Date(2022, 5, 24, 20, 0, 0, 0, time.UTC),
+			expectedBuildIDs: []string{"build1a", "build1b", "build3"},
+		},
+		{
+			desc:             "when both filters are set, it applies both",
+			latestRunOnly:    true,
+			finishedAfter:    time.Date(2022, 5, 24, 20, 20, 0, 0, time.UTC),
+			expectedBuildIDs: []string{"build1b", "build3"},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			buildIDGetter := NewBuildIDGetter(finishedStages, selector.Filter{}, false, log.NewLogger())
+			buildIDGetter.LatestRunOnly = tC.latestRunOnly
+			buildIDGetter.FinishedAfter = tC.finishedAfter
+
+			buildIDs, err := buildIDGetter.GetBuildIDs()
+			assert.NoError(t, err)
+
+			sort.Strings(buildIDs)
+			assert.Equal(t, tC.expectedBuildIDs, buildIDs)
+		})
+	}
+}
+
+func Test_GetBuildIDs_keeps_stage_and_workflow_order(t *testing.T) {
+	finishedStages := model.FinishedStages{
+		{
+			Name: "build",
+			Workflows: []model.Workflow{
+				{Name: "ios", ExternalId: "build-c"},
+				{Name: "android", ExternalId: "build-a"},
+			},
+		},
+		{
+			Name: "test",
+			Workflows: []model.Workflow{
+				{Name: "unit-test", ExternalId: "build-b"},
+			},
+		},
+	}
+
+	filter, err := selector.ParseFilter(".*", "", selector.SyntaxLegacy)
+	assert.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		buildIDs, err := NewBuildIDGetter(finishedStages, filter, false, log.NewLogger()).GetBuildIDs()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"build-c", "build-a", "build-b"}, buildIDs)
+	}
+}
@@ -2,37 +2,64 @@ package downloader
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"path/filepath"
 	"time"
 
-	"github.com/bitrise-io/go-utils/filedownloader"
 	"github.com/bitrise-io/go-utils/log"
-	"github.com/bitrise-io/go-utils/retry"
 	"github.com/bitrise-steplib/bitrise-step-artifact-pull/api"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/redact"
 )
 
 const (
-	filePermission               = 0o655
-	maxConcurrentDownloadThreads = 10
+	filePermission          = 0o655
+	defaultThreads          = 10
+	defaultProgressInterval = 10 * time.Second
 )
 
 type ConcurrentArtifactDownloader struct {
 	Artifacts []api.ArtifactResponseItemModel
 	Logger    log.Logger
 	TargetDir string
-	Timeout   time.Duration
+	// Timeout is the timeout of each artifact download, including the retries. The time spent waiting for the bandwidth
+	// limit (see MaxBytesPerSecond) is not counted.
+	Timeout time.Duration
+	// Threads is the number of concurrent downloads, the default is used if it is not positive
+	Threads int
+	// Context aborts the downloads once it is done (like at a step deadline), context.Background() is used if it is nil
+	Context context.Context
+	// HTTPClient is shared by the download workers, a client with the DefaultClientConfig is used if it is nil
+	HTTPClient *http.Client
+	// MaxBytesPerSecond caps the total bandwidth of the concurrent downloads, they are not limited if it is 0
+	MaxBytesPerSecond int64
+	// ProgressInterval is the interval of the progress log messages, no progress is logged if it is 0
+	ProgressInterval time.Duration
 }
 
 type ArtifactDownloadResult struct {
 	DownloadError error
 	DownloadPath  string
 	DownloadURL   string
+	Title         string
+	BuildSlug     string
+	// Size is the size of the downloaded file in bytes
+	Size int64
+	// Duration is the time spent on the download, including the retries
+	Duration time.Duration
+}
+
+type indexedDownloadResult struct {
+	index  int
+	result ArtifactDownloadResult
 }
 
 type downloadJob struct {
+	Index         int
 	ResponseModel api.ArtifactResponseItemModel
-	TargetDir     string
+	FilePath      string
 }
 
 func (ad *ConcurrentArtifactDownloader) DownloadAndSaveArtifacts() ([]ArtifactDownloadResult, error) {
@@ -45,50 +72,174 @@ func (ad *ConcurrentArtifactDownloader) DownloadAndSaveArtifacts() ([]ArtifactDo
 	return ad.downloadParallel(ad.TargetDir)
 }
 
+// downloadParallel downloads the artifacts concurrently, and returns the results in the order of the artifacts
 func (ad *ConcurrentArtifactDownloader) downloadParallel(targetDir string) ([]ArtifactDownloadResult, error) {
-	var downloadResults []ArtifactDownloadResult
-
 	jobs := make(chan downloadJob, len(ad.Artifacts))
-	results := make(chan ArtifactDownloadResult, len(ad.Artifacts))
+	results := make(chan indexedDownloadResult, len(ad.Artifacts))
+
+	client := ad.HTTPClient
+	if client == nil {
+		cfg := DefaultClientConfig()
+		transport, err := NewTransport(cfg)
+		if err != nil {
+			return nil, err
+		}
+		client = NewHTTPClient(cfg, transport, ad.Logger)
+	}
 
-	for i := 0; i < maxConcurrentDownloadThreads; i++ {
-		go ad.download(jobs, results)
+	var limiter *rateLimiter
+	if ad.MaxBytesPerSecond > 0 {
+		limiter = newRateLimiter(ad.MaxBytesPerSecond)
 	}
 
+	progress := &progress{}
 	for _, artifact := range ad.Artifacts {
+		progress.addTotal(artifact.FileSizeBytes)
+	}
+	if ad.ProgressInterval > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		go reportProgress(progress, ad.ProgressInterval, ad.Logger, done)
+	}
+
+	threads := ad.Threads
+	if threads < 1 {
+		threads = defaultThreads
+	}
+	for i := 0; i < threads; i++ {
+		go ad.download(client, limiter, jobs, results, progress)
+	}
+
+	filePaths := downloadPaths(targetDir, ad.Artifacts)
+	for i, artifact := range ad.Artifacts {
 		jobs <- downloadJob{
+			Index:         i,
 			ResponseModel: artifact,
-			TargetDir:     targetDir,
+			FilePath:      filePaths[i],
 		}
 	}
 	close(jobs)
 
+	downloadResults := make([]ArtifactDownloadResult, len(ad.Artifacts))
 	for i := 0; i < len(ad.Artifacts); i++ {
 		res := <-results
-		downloadResults = append(downloadResults, res)
+		downloadResults[res.index] = res.result
 	}
 
 	return downloadResults, nil
 }
 
-func (ad *ConcurrentArtifactDownloader) download(jobs <-chan downloadJob, results chan<- ArtifactDownloadResult) {
+func (ad *ConcurrentArtifactDownloader) download(client *http.Client, limiter *rateLimiter, jobs <-chan downloadJob, results chan<- indexedDownloadResult, progress *progress) {
 	for j := range jobs {
-		fileFullPath := filepath.Join(j.TargetDir, j.ResponseModel.Title)
+		fileFullPath := j.FilePath
+		if err := os.MkdirAll(filepath.Dir(fileFullPath), 0o755); err != nil {
+			results <- indexedDownloadResult{index: j.Index, result: ArtifactDownloadResult{DownloadError: err, DownloadURL: j.ResponseModel.DownloadURL, Title: j.ResponseModel.Title, BuildSlug: j.ResponseModel.BuildSlug}}
+			continue
+		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), ad.Timeout)
+		start := time.Now()
+		ctx, cancel := withThrottledTimeout(ad.context(), ad.Timeout)
 
-		downloader := filedownloader.NewWithContext(ctx, retry.NewHTTPClient().StandardClient())
-		err := downloader.Get(fileFullPath, j.ResponseModel.DownloadURL)
+		size, err := ad.downloadFile(ctx, client, limiter, fileFullPath, j.ResponseModel, progress)
+		if err != nil && ctx.exceeded() {
+			// the requests report the cancellation of the timeout as context.Canceled
+			err = fmt.Errorf("the download did not finish within %s: %w", ad.Timeout, context.DeadlineExceeded)
+		}
 
 		cancel()
+		duration := time.Since(start)
 
 		if err != nil {
-			results <- ArtifactDownloadResult{DownloadError: err, DownloadURL: j.ResponseModel.DownloadURL}
-			return
+			// the download errors contain the expiring download URL with its signature
+			err = redact.New().Error(err)
+			results <- indexedDownloadResult{index: j.Index, result: ArtifactDownloadResult{DownloadError: err, DownloadURL: j.ResponseModel.DownloadURL, Title: j.ResponseModel.Title, BuildSlug: j.ResponseModel.BuildSlug, Duration: duration}}
+			continue
+		}
+
+		results <- indexedDownloadResult{index: j.Index, result: ArtifactDownloadResult{DownloadPath: fileFullPath, DownloadURL: j.ResponseModel.DownloadURL, Title: j.ResponseModel.Title, BuildSlug: j.ResponseModel.BuildSlug, Size: size, Duration: duration}}
+	}
+}
+
+func (ad *ConcurrentArtifactDownloader) context() context.Context {
+	if ad.Context == nil {
+		return context.Background()
+	}
+
+	return ad.Context
+}
+
+// downloadFile downloads the artifact into the destination file, and returns the number of the downloaded bytes.
+// The transferred bytes are counted into the progress as they arrive, at the rate allowed by the limiter (if it is set).
+func (ad *ConcurrentArtifactDownloader) downloadFile(ctx *throttledTimeout, client *http.Client, limiter *rateLimiter, destination string, artifact api.ArtifactResponseItemModel, progress *progress) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, artifact.DownloadURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %s", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			ad.Logger.Warnf("Failed to close response body: %s", err)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unable to download file from: %s. Status code: %d", artifact.DownloadURL, resp.StatusCode)
+	}
+
+	f, err := os.Create(destination)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			ad.Logger.Warnf("Failed to close file: %s", err)
 		}
+	}()
 
-		results <- ArtifactDownloadResult{DownloadPath: fileFullPath, DownloadURL: j.ResponseModel.DownloadURL}
+	// the size of the artifacts not reported by the API is taken from the response
+	if artifact.FileSizeBytes == 0 && resp.ContentLength > 0 {
+		progress.addTotal(resp.ContentLength)
 	}
+
+	progress.startTransfer()
+	defer progress.finishTransfer()
+
+	var reader io.Reader = resp.Body
+	if limiter != nil {
+		reader = &rateLimitedReader{ctx: ctx, reader: reader, limiter: limiter, throttled: ctx.addThrottled}
+	}
+
+	body := &countingReader{reader: reader, progress: progress}
+	if _, err := io.Copy(f, body); err != nil {
+		return body.read, err
+	}
+
+	return body.read, nil
+}
+
+// downloadPaths returns the target path of each artifact: <target dir>/<title>, or if an earlier artifact has the same
+// title, <target dir>/<build slug>/<title>
+func downloadPaths(targetDir string, artifacts []api.ArtifactResponseItemModel) []string {
+	var paths []string
+	used := make(map[string]bool)
+	for _, artifact := range artifacts {
+		pth := filepath.Join(targetDir, artifact.Title)
+		if used[pth] {
+			pth = filepath.Join(targetDir, artifact.BuildSlug, artifact.Title)
+		}
+		for i := 2; used[pth]; i++ {
+			pth = filepath.Join(targetDir, fmt.Sprintf("%s-%d", artifact.BuildSlug, i), artifact.Title)
+		}
+		used[pth] = true
+
+		paths = append(paths, pth)
+	}
+
+	return paths
 }
 
 func NewConcurrentArtifactDownloader(artifacts []api.ArtifactResponseItemModel, timeout time.Duration, targetDir string, logger log.Logger) *ConcurrentArtifactDownloader {
@@ -97,5 +248,8 @@ func NewConcurrentArtifactDownloader(artifacts []api.ArtifactResponseItemModel,
 		Timeout:   timeout,
 		Logger:    logger,
 		TargetDir: targetDir,
+		Threads:   defaultThreads,
+		// the progress is logged at most once in the interval, so that long downloads don't flood the log
+		ProgressInterval: defaultProgressInterval,
 	}
 }
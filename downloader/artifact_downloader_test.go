@@ -38,10 +38,13 @@ func Test_DownloadAndSaveArtifacts(t *testing.T) {
 	var expectedDownloadResults []ArtifactDownloadResult
 	for i := 1; i <= 11; i++ {
 		downloadURL := fmt.Sprintf(svr.URL+"/%d.txt", i)
-		artifacts = append(artifacts, api.ArtifactResponseItemModel{DownloadURL: downloadURL, Title: fmt.Sprintf("%d.txt", i)})
+		artifacts = append(artifacts, api.ArtifactResponseItemModel{DownloadURL: downloadURL, Title: fmt.Sprintf("%d.txt", i), BuildSlug: "build-slug"})
 		expectedDownloadResults = append(expectedDownloadResults, ArtifactDownloadResult{
 			DownloadPath: targetDir + fmt.Sprintf("/%d.txt", i),
 			DownloadURL:  downloadURL,
+			Title:        fmt.Sprintf("%d.txt", i),
+			BuildSlug:    "build-slug",
+			Size:         int64(len("dummy data")),
 		})
 	}
 
@@ -50,7 +53,11 @@ func Test_DownloadAndSaveArtifacts(t *testing.T) {
 	downloadResults, err := artifactDownloader.DownloadAndSaveArtifacts()
 
 	assert.NoError(t, err)
-	assert.ElementsMatch(t, expectedDownloadResults, downloadResults)
+	for i := range downloadResults {
+		assert.True(t, downloadResults[i].Duration > 0)
+		downloadResults[i].Duration = 0
+	}
+	assert.Equal(t, expectedDownloadResults, downloadResults)
 
 	_ = os.RemoveAll(targetDir)
 }
@@ -77,3 +84,52 @@ func Test_DownloadAndSaveArtifacts_DownloadFails(t *testing.T) {
 
 	_ = os.RemoveAll(targetDir)
 }
+
+func Test_DownloadAndSaveArtifacts_AllDownloadsFail(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer svr.Close()
+
+	targetDir, err := getDownloadDir(relativeDownloadPath)
+	assert.NoError(t, err)
+
+	var artifacts []api.ArtifactResponseItemModel
+	for i := 1; i <= 2*defaultThreads; i++ {
+		artifacts = append(artifacts, api.ArtifactResponseItemModel{DownloadURL: fmt.Sprintf("%s/%d.txt", svr.URL, i), Title: fmt.Sprintf("%d.txt", i)})
+	}
+
+	artifactDownloader := NewConcurrentArtifactDownloader(artifacts, 5*time.Minute, targetDir, log.NewLogger())
+
+	results, err := artifactDownloader.DownloadAndSaveArtifacts()
+
+	assert.NoError(t, err)
+	assert.Len(t, results, len(artifacts))
+	for i, result := range results {
+		assert.EqualError(t, result.DownloadError, fmt.Sprintf("unable to download file from: %s/%d.txt. Status code: 401", svr.URL, i+1))
+	}
+
+	_ = os.RemoveAll(targetDir)
+}
+
+func Test_downloadPaths(t *testing.T) {
+	artifacts := []api.ArtifactResponseItemModel{
+		{Title: "app.apk", BuildSlug: "build-1"},
+		{Title: "app.apk", BuildSlug: "build-2"},
+		{Title: "app.ipa", BuildSlug: "build-2"},
+		{Title: "app.apk", BuildSlug: "build-2"},
+		{Title: "app.apk", BuildSlug: "build-2"},
+		{Title: "app.apk", BuildSlug: "build-3"},
+		{Title: "build-2/app.apk", BuildSlug: "build-4"},
+	}
+
+	assert.Equal(t, []string{
+		"/tmp/pull/app.apk",
+		"/tmp/pull/build-2/app.apk",
+		"/tmp/pull/app.ipa",
+		"/tmp/pull/build-2-2/app.apk",
+		"/tmp/pull/build-2-3/app.apk",
+		"/tmp/pull/build-3/app.apk",
+		"/tmp/pull/build-4/build-2/app.apk",
+	}, downloadPaths("/tmp/pull", artifacts))
+}
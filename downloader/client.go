@@ -0,0 +1,128 @@
+package downloader
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/retry"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/redact"
+)
+
+// ClientConfig configures the HTTP client shared by the artifact downloads
+type ClientConfig struct {
+	// MaxConnsPerHost limits the connections (active and idle) to the storage host, the downloads over the limit wait
+	// for a free connection
+	MaxConnsPerHost int
+	HTTP2           bool
+	// AttemptTimeout is the time to wait for the response headers of each attempt, the body can take longer
+	AttemptTimeout time.Duration
+	RetryMax       int
+	RetryWaitMax   time.Duration
+	// ProxyURL is the proxy of the downloads, the HTTP(S)_PROXY environment variables are used if it is empty
+	ProxyURL string
+	// CABundlePath is a PEM file of the certificates to trust besides the system certificates
+	CABundlePath string
+}
+
+// DefaultClientConfig returns the settings used if the step inputs do not override them
+func DefaultClientConfig() ClientConfig {
+	return ClientConfig{
+		MaxConnsPerHost: defaultThreads,
+		HTTP2:           true,
+		AttemptTimeout:  time.Minute,
+		RetryMax:        4,
+		RetryWaitMax:    30 * time.Second,
+	}
+}
+
+// NewTransport returns a transport with the connection pooling, HTTP/2, timeout, proxy and TLS settings of the config
+func NewTransport(cfg ClientConfig) (*http.Transport, error) {
+	proxy := http.ProxyFromEnvironment
+	if cfg.ProxyURL != "" {
+		proxyURL, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	transport := &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     cfg.HTTP2,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		MaxIdleConns:          cfg.MaxConnsPerHost,
+		MaxIdleConnsPerHost:   cfg.MaxConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: cfg.AttemptTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+	if !cfg.HTTP2 {
+		// a non-nil, empty map disables the HTTP/2 upgrade
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	if cfg.CABundlePath != "" {
+		rootCAs, err := certPool(cfg.CABundlePath)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: rootCAs, MinVersion: tls.VersionTLS12}
+	}
+
+	return transport, nil
+}
+
+// NewHTTPClient returns a retrying client with the retry policy of the config, sending the requests with the given
+// transport (see NewTransport). The client is safe to share between the download workers.
+func NewHTTPClient(cfg ClientConfig, transport http.RoundTripper, logger log.Logger) *http.Client {
+	retryClient := retry.NewHTTPClient()
+	retryClient.HTTPClient = &http.Client{Transport: transport}
+	retryClient.RetryMax = cfg.RetryMax
+	if cfg.RetryWaitMax > 0 {
+		retryClient.RetryWaitMax = cfg.RetryWaitMax
+		if retryClient.RetryWaitMin > cfg.RetryWaitMax {
+			retryClient.RetryWaitMin = cfg.RetryWaitMax
+		}
+	}
+	retryClient.Logger = retryLogger{logger: redact.New().Logger(logger)}
+
+	return retryClient.StandardClient()
+}
+
+func certPool(caBundlePath string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(caBundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the CA bundle: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in the CA bundle: %s", caBundlePath)
+	}
+
+	return pool, nil
+}
+
+// retryLogger passes the messages of the retrying HTTP client (which contain the download URLs) to the debug log
+type retryLogger struct {
+	logger log.Logger
+}
+
+func (l retryLogger) Printf(format string, v ...interface{}) {
+	l.logger.Debugf(format, v...)
+}
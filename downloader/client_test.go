@@ -0,0 +1,118 @@
+package downloader
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTransport(t *testing.T) {
+	transport, err := NewTransport(ClientConfig{MaxConnsPerHost: 16, HTTP2: false, AttemptTimeout: time.Minute, ProxyURL: "http://proxy.example.com:3128"})
+	assert.NoError(t, err)
+	assert.Equal(t, 16, transport.MaxConnsPerHost)
+	assert.Equal(t, 16, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, time.Minute, transport.ResponseHeaderTimeout)
+	assert.False(t, transport.ForceAttemptHTTP2)
+	assert.NotNil(t, transport.TLSNextProto)
+
+	req, err := http.NewRequest(http.MethodGet, "https://storage.example.com/a.apk", nil)
+	assert.NoError(t, err)
+	proxyURL, err := transport.Proxy(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://proxy.example.com:3128", proxyURL.String())
+}
+
+func TestNewTransport_CABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "dummy data")
+	}))
+	defer server.Close()
+
+	caBundle := filepath.Join(t.TempDir(), "ca.pem")
+	assert.NoError(t, ioutil.WriteFile(caBundle, pemCertificate(server.Certificate().Raw), 0o600))
+
+	transport, err := NewTransport(ClientConfig{CABundlePath: caBundle})
+	assert.NoError(t, err)
+
+	resp, err := NewHTTPClient(ClientConfig{}, transport, log.NewLogger()).Get(server.URL)
+	assert.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	invalidBundle := filepath.Join(t.TempDir(), "invalid.pem")
+	assert.NoError(t, ioutil.WriteFile(invalidBundle, []byte("not a certificate"), 0o600))
+	_, err = NewTransport(ClientConfig{CABundlePath: invalidBundle})
+	assert.EqualError(t, err, "no certificate found in the CA bundle: "+invalidBundle)
+}
+
+func TestNewHTTPClient_RetryPolicy(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(ClientConfig{RetryMax: 2, RetryWaitMax: time.Millisecond}, http.DefaultTransport, log.NewLogger())
+	resp, err := client.Get(server.URL)
+	assert.NoError(t, err)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+}
+
+func Test_DownloadAndSaveArtifacts_SharedClient(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "dummy data")
+	}))
+	defer svr.Close()
+
+	var roundTrips int32
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&roundTrips, 1)
+		return http.DefaultTransport.RoundTrip(req)
+	})
+
+	artifactDownloader := NewConcurrentArtifactDownloader(testArtifacts(svr.URL, 3), 5*time.Minute, t.TempDir(), log.NewLogger())
+	artifactDownloader.HTTPClient = NewHTTPClient(DefaultClientConfig(), transport, log.NewLogger())
+
+	results, err := artifactDownloader.DownloadAndSaveArtifacts()
+	assert.NoError(t, err)
+	for _, result := range results {
+		assert.NoError(t, result.DownloadError)
+		_, err := os.Stat(result.DownloadPath)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&roundTrips))
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func testArtifacts(baseURL string, count int) []api.ArtifactResponseItemModel {
+	var artifacts []api.ArtifactResponseItemModel
+	for i := 1; i <= count; i++ {
+		artifacts = append(artifacts, api.ArtifactResponseItemModel{DownloadURL: fmt.Sprintf("%s/%d.txt", baseURL, i), Title: fmt.Sprintf("%d.txt", i), BuildSlug: "build-slug"})
+	}
+
+	return artifacts
+}
+
+func pemCertificate(der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
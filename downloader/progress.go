@@ -0,0 +1,94 @@
+package downloader
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/humanize"
+)
+
+// progress tracks the bytes transferred by the concurrent downloads
+type progress struct {
+	total       int64
+	transferred int64
+	active      int64
+}
+
+func (p *progress) addTotal(n int64) {
+	atomic.AddInt64(&p.total, n)
+}
+
+func (p *progress) addTransferred(n int64) {
+	atomic.AddInt64(&p.transferred, n)
+}
+
+func (p *progress) startTransfer() {
+	atomic.AddInt64(&p.active, 1)
+}
+
+func (p *progress) finishTransfer() {
+	atomic.AddInt64(&p.active, -1)
+}
+
+func (p *progress) snapshot() (total, transferred, active int64) {
+	return atomic.LoadInt64(&p.total), atomic.LoadInt64(&p.transferred), atomic.LoadInt64(&p.active)
+}
+
+// countingReader counts the bytes read from the response body into the progress
+type countingReader struct {
+	reader   io.Reader
+	progress *progress
+	read     int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.read += int64(n)
+		r.progress.addTransferred(int64(n))
+	}
+
+	return n, err
+}
+
+// reportProgress logs the progress of the downloads at every interval, until done is closed. Nothing is logged while
+// there is no active transfer.
+func reportProgress(p *progress, interval time.Duration, logger log.Logger, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var previous int64
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			total, transferred, active := p.snapshot()
+			rate := float64(transferred-previous) / interval.Seconds()
+			previous = transferred
+			if active == 0 {
+				continue
+			}
+
+			logger.Printf("%s", progressLine(total, transferred, active, rate))
+		}
+	}
+}
+
+// progressLine returns the progress message, like: Downloaded 1.2 GB of 3.4 GB (35%), 25.0 MB/s, ETA 1m30s, 4 active transfers
+func progressLine(total, transferred, active int64, rate float64) string {
+	line := fmt.Sprintf("Downloaded %s", humanize.Bytes(transferred))
+	if total >= transferred && total > 0 {
+		line += fmt.Sprintf(" of %s (%d%%)", humanize.Bytes(total), transferred*100/total)
+	}
+	line += fmt.Sprintf(", %s/s", humanize.Bytes(int64(rate)))
+	if total > transferred && rate > 0 {
+		eta := time.Duration(float64(total-transferred) / rate * float64(time.Second))
+		line += fmt.Sprintf(", ETA %s", eta.Round(time.Second))
+	}
+
+	return line + fmt.Sprintf(", %d active transfers", active)
+}
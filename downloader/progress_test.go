@@ -0,0 +1,111 @@
+package downloader
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type messageLogger struct {
+	log.Logger
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *messageLogger) Printf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.messages = append(l.messages, fmt.Sprintf(format, v...))
+}
+
+func (l *messageLogger) Messages() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]string{}, l.messages...)
+}
+
+func Test_progressLine(t *testing.T) {
+	tests := []struct {
+		name        string
+		total       int64
+		transferred int64
+		active      int64
+		rate        float64
+		want        string
+	}{
+		{
+			name:        "known total",
+			total:       4 * 1024 * 1024,
+			transferred: 1024 * 1024,
+			active:      2,
+			rate:        1024 * 1024,
+			want:        "Downloaded 1.0 MB of 4.0 MB (25%), 1.0 MB/s, ETA 3s, 2 active transfers",
+		},
+		{
+			name:        "unknown total",
+			transferred: 2048,
+			active:      1,
+			rate:        1024,
+			want:        "Downloaded 2.0 KB, 1.0 KB/s, 1 active transfers",
+		},
+		{
+			name:        "stalled",
+			total:       2048,
+			transferred: 1024,
+			active:      1,
+			want:        "Downloaded 1.0 KB of 2.0 KB (50%), 0 B/s, 1 active transfers",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, progressLine(tt.total, tt.transferred, tt.active, tt.rate))
+		})
+	}
+}
+
+func Test_countingReader(t *testing.T) {
+	p := &progress{}
+	reader := &countingReader{reader: strings.NewReader("dummy data"), progress: p}
+
+	content, err := ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "dummy data", string(content))
+	assert.Equal(t, int64(10), reader.read)
+
+	_, transferred, _ := p.snapshot()
+	assert.Equal(t, int64(10), transferred)
+}
+
+func Test_reportProgress(t *testing.T) {
+	p := &progress{}
+	logger := &messageLogger{}
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		reportProgress(p, 10*time.Millisecond, logger, done)
+		close(finished)
+	}()
+
+	// nothing is logged without active transfers
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, logger.Messages())
+
+	p.addTotal(100)
+	p.startTransfer()
+	p.addTransferred(50)
+	time.Sleep(50 * time.Millisecond)
+	close(done)
+	<-finished
+
+	messages := logger.Messages()
+	assert.NotEmpty(t, messages)
+	assert.True(t, strings.HasPrefix(messages[0], "Downloaded 50 B of 100 B (50%)"), messages[0])
+}
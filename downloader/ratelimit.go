@@ -0,0 +1,111 @@
+package downloader
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// minBurst is the smallest chunk read at once from a rate limited body, unless the rate itself is lower
+const minBurst = 32 * 1024
+
+// rateLimiter is a token bucket shared by the download workers, refilled with the allowed bytes per second
+type rateLimiter struct {
+	rate  float64
+	burst int64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	// a tenth of a second worth of bytes keeps the transfer smooth without too many wake-ups
+	burst := bytesPerSecond / 10
+	if burst < minBurst {
+		burst = minBurst
+	}
+	// a burst above the rate would let more than the limit through in the first second and after every idle period
+	if burst > bytesPerSecond {
+		burst = bytesPerSecond
+	}
+
+	return &rateLimiter{
+		rate:   float64(bytesPerSecond),
+		burst:  burst,
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// reserve takes n tokens from the bucket, and returns the time to wait until they are available. The bucket can go
+// into debt, so the concurrent readers are served in the order of their reservations.
+func (l *rateLimiter) reserve(n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > float64(l.burst) {
+		l.tokens = float64(l.burst)
+	}
+	l.last = now
+
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// wait blocks until n bytes are allowed to be transferred, or the context is done
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	return sleep(ctx, l.reserve(n))
+}
+
+// sleep blocks for the delay, or until the context is done
+func sleep(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// rateLimitedReader reads at most a burst at once, and waits for the limiter after each read
+type rateLimitedReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	limiter *rateLimiter
+	// throttled is called with the time to wait for the limiter before each wait, if it is set
+	throttled func(time.Duration)
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > r.limiter.burst {
+		p = p[:r.limiter.burst]
+	}
+
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		delay := r.limiter.reserve(n)
+		if delay > 0 && r.throttled != nil {
+			r.throttled(delay)
+		}
+		if waitErr := sleep(r.ctx, delay); waitErr != nil {
+			return n, waitErr
+		}
+	}
+
+	return n, err
+}
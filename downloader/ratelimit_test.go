@@ -0,0 +1,84 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_rateLimiter_reserve(t *testing.T) {
+	limiter := newRateLimiter(minBurst)
+
+	// the bucket starts full
+	assert.Equal(t, time.Duration(0), limiter.reserve(minBurst))
+
+	// the next reservation waits for the bucket to refill
+	delay := limiter.reserve(minBurst / 2)
+	assert.True(t, delay > 400*time.Millisecond && delay <= 500*time.Millisecond, delay)
+}
+
+func Test_rateLimiter_waitIsCancelled(t *testing.T) {
+	limiter := newRateLimiter(minBurst)
+	limiter.reserve(minBurst)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.Equal(t, context.Canceled, limiter.wait(ctx, minBurst))
+}
+
+func Test_rateLimitedReader(t *testing.T) {
+	content := bytes.Repeat([]byte("a"), 3*minBurst)
+	reader := &rateLimitedReader{ctx: context.Background(), reader: bytes.NewReader(content), limiter: newRateLimiter(4 * minBurst)}
+
+	start := time.Now()
+	read, err := ioutil.ReadAll(reader)
+
+	assert.NoError(t, err)
+	assert.Equal(t, content, read)
+	// the first burst is available at once, the rest arrives at the rate
+	assert.True(t, time.Since(start) >= 400*time.Millisecond, time.Since(start))
+}
+
+func Test_rateLimitedReader_RateBelowMinBurst(t *testing.T) {
+	content := bytes.Repeat([]byte("a"), 3000)
+	reader := &rateLimitedReader{ctx: context.Background(), reader: bytes.NewReader(content), limiter: newRateLimiter(2000)}
+
+	start := time.Now()
+	read, err := ioutil.ReadAll(reader)
+
+	assert.NoError(t, err)
+	assert.Equal(t, content, read)
+	// the burst is capped at the rate: the first 2000 bytes are available at once, the rest takes half a second
+	elapsed := time.Since(start)
+	assert.True(t, elapsed >= 450*time.Millisecond && elapsed < 2*time.Second, elapsed)
+}
+
+func Test_DownloadAndSaveArtifacts_BandwidthIsShared(t *testing.T) {
+	content := bytes.Repeat([]byte("a"), 2*minBurst)
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(content)
+	}))
+	defer svr.Close()
+
+	artifactDownloader := NewConcurrentArtifactDownloader(testArtifacts(svr.URL, 3), 5*time.Minute, t.TempDir(), log.NewLogger())
+	artifactDownloader.MaxBytesPerSecond = 8 * minBurst
+
+	start := time.Now()
+	results, err := artifactDownloader.DownloadAndSaveArtifacts()
+
+	assert.NoError(t, err)
+	for _, result := range results {
+		assert.NoError(t, result.DownloadError)
+		assert.Equal(t, int64(len(content)), result.Size)
+	}
+	// 6 bursts at 8 bursts per second, the first one is available at once
+	assert.True(t, time.Since(start) >= 500*time.Millisecond, time.Since(start))
+}
@@ -0,0 +1,65 @@
+package downloader
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// throttledTimeout is a context done once the download has been running for the timeout, not counting the time
+// spent waiting for the rate limiter. A bandwidth limit therefore does not make the downloads time out.
+type throttledTimeout struct {
+	context.Context
+	cancel context.CancelFunc
+
+	// throttled is the time spent waiting for the rate limiter in nanoseconds
+	throttled int64
+	timedOut  int32
+}
+
+func withThrottledTimeout(parent context.Context, timeout time.Duration) (*throttledTimeout, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	t := &throttledTimeout{Context: ctx, cancel: cancel}
+	go t.watch(time.Now(), timeout)
+
+	return t, cancel
+}
+
+// addThrottled extends the timeout with the time waited for the rate limiter
+func (t *throttledTimeout) addThrottled(d time.Duration) {
+	atomic.AddInt64(&t.throttled, int64(d))
+}
+
+// Err returns context.DeadlineExceeded once the timeout is exceeded, like the contexts with a deadline
+func (t *throttledTimeout) Err() error {
+	if t.exceeded() {
+		return context.DeadlineExceeded
+	}
+
+	return t.Context.Err()
+}
+
+// exceeded reports whether the context is done because of the timeout (and not because of its parent)
+func (t *throttledTimeout) exceeded() bool {
+	return atomic.LoadInt32(&t.timedOut) == 1
+}
+
+func (t *throttledTimeout) watch(start time.Time, timeout time.Duration) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-t.Done():
+			return
+		case <-timer.C:
+			remaining := timeout + time.Duration(atomic.LoadInt64(&t.throttled)) - time.Since(start)
+			if remaining <= 0 {
+				atomic.StoreInt32(&t.timedOut, 1)
+				t.cancel()
+				return
+			}
+			timer.Reset(remaining)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_withThrottledTimeout(t *testing.T) {
+	ctx, cancel := withThrottledTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	ctx.addThrottled(200 * time.Millisecond)
+
+	select {
+	case <-ctx.Done():
+		t.Fatal("the throttled time is counted into the timeout")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	select {
+	case <-ctx.Done():
+		assert.Equal(t, context.DeadlineExceeded, ctx.Err())
+	case <-time.After(time.Second):
+		t.Fatal("the timeout is not exceeded")
+	}
+}
+
+func Test_withThrottledTimeout_parentCancelled(t *testing.T) {
+	parent, cancelParent := context.WithCancel(context.Background())
+	ctx, cancel := withThrottledTimeout(parent, time.Minute)
+	defer cancel()
+
+	cancelParent()
+	<-ctx.Done()
+	assert.Equal(t, context.Canceled, ctx.Err())
+}
+
+func Test_DownloadAndSaveArtifacts_ThrottlingDoesNotTimeOut(t *testing.T) {
+	content := bytes.Repeat([]byte("a"), 2*minBurst)
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(content)
+	}))
+	defer svr.Close()
+
+	// the second half of the content arrives after half a second at the limit, later than the timeout
+	artifactDownloader := NewConcurrentArtifactDownloader(testArtifacts(svr.URL, 1), 200*time.Millisecond, t.TempDir(), log.NewLogger())
+	artifactDownloader.MaxBytesPerSecond = 2 * minBurst
+
+	results, err := artifactDownloader.DownloadAndSaveArtifacts()
+	assert.NoError(t, err)
+	assert.NoError(t, results[0].DownloadError)
+	assert.Equal(t, int64(len(content)), results[0].Size)
+}
+
+func Test_DownloadAndSaveArtifacts_TimesOut(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer svr.Close()
+
+	artifactDownloader := NewConcurrentArtifactDownloader(testArtifacts(svr.URL, 1), 200*time.Millisecond, t.TempDir(), log.NewLogger())
+
+	results, err := artifactDownloader.DownloadAndSaveArtifacts()
+	assert.NoError(t, err)
+	assert.EqualError(t, results[0].DownloadError, "the download did not finish within 200ms: context deadline exceeded")
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-steputils/stepconf"
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/fakeapi"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryEnvRepository is an in-memory env.Repository, it holds the step inputs and receives the exported outputs
+type memoryEnvRepository struct {
+	mu   sync.Mutex
+	envs map[string]string
+}
+
+func newMemoryEnvRepository(envs map[string]string) *memoryEnvRepository {
+	return &memoryEnvRepository{envs: envs}
+}
+
+func (r *memoryEnvRepository) List() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var envs []string
+	for key, value := range r.envs {
+		envs = append(envs, key+"="+value)
+	}
+	sort.Strings(envs)
+
+	return envs
+}
+
+func (r *memoryEnvRepository) Unset(key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.envs, key)
+
+	return nil
+}
+
+func (r *memoryEnvRepository) Get(key string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.envs[key]
+}
+
+func (r *memoryEnvRepository) Set(key, value string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.envs[key] = value
+
+	return nil
+}
+
+// e2eFinishedStages are the stages of the pipeline, the builds are served by e2eSpec
+var e2eFinishedStages = model.FinishedStages{
+	{Name: "build", Workflows: []model.Workflow{
+		{Name: "android", ExternalId: "build-android"},
+		{Name: "ios", ExternalId: "build-ios"},
+	}},
+	{Name: "test", Workflows: []model.Workflow{
+		{Name: "unit-test", ExternalId: "build-unit-test"},
+	}},
+}
+
+func e2eSpec() fakeapi.Spec {
+	artifact := func(title, content string) fakeapi.Artifact {
+		return fakeapi.Artifact{Slug: "slug-" + title, Title: title, Content: []byte(content)}
+	}
+
+	return fakeapi.Spec{
+		Token:    "token",
+		PageSize: 2,
+		Apps: map[string]fakeapi.App{
+			"app-slug": {Builds: []fakeapi.Build{
+				{Slug: "build-android", Workflow: "android", Status: 1, Artifacts: []fakeapi.Artifact{
+					artifact("app.apk", "android app"),
+					artifact("mapping.txt", "android mapping"),
+					artifact("version.txt", "android version"),
+				}},
+				{Slug: "build-ios", Workflow: "ios", Status: 1, Artifacts: []fakeapi.Artifact{
+					artifact("app.ipa", "ios app"),
+					artifact("version.txt", "ios version"),
+				}},
+				{Slug: "build-unit-test", Workflow: "unit-test", Status: 1, Artifacts: []fakeapi.Artifact{
+					artifact("junit.xml", "<testsuite/>"),
+				}},
+			}},
+		},
+	}
+}
+
+type e2eRun struct {
+	envs   *memoryEnvRepository
+	result Result
+	err    error
+}
+
+// runStep drives ArtifactPull from the env inputs to the exported outputs against the fake API
+func runStep(t *testing.T, server *fakeapi.Server, inputs map[string]string) e2eRun {
+	t.Setenv("TMPDIR", t.TempDir())
+
+	finishedStages, err := json.Marshal(e2eFinishedStages)
+	assert.NoError(t, err)
+
+	envs := map[string]string{
+		"BITRISE_APP_SLUG":               "app-slug",
+		"verbose":                        "false",
+		"artifact_sources":               ".*",
+		"artifact_sources_syntax":        "legacy",
+		"fail_on_unmatched_sources":      "false",
+		"latest_workflow_run_only":       "false",
+		"export_destination_mode":        "copy",
+		"export_encoding":                "pipe",
+		"export_grouped_paths":           "false",
+		"post_download_hook_parallelism": "4",
+		"download_http2":                 "true",
+		"build_selection_mode":           "pipeline",
+		"finished_stage":                 string(finishedStages),
+		"bitrise_api_access_token":       "token",
+		"bitrise_api_base_url":           server.Start(),
+	}
+	t.Cleanup(server.Close)
+	for key, value := range inputs {
+		envs[key] = value
+	}
+
+	envRepository := newMemoryEnvRepository(envs)
+	step := ArtifactPull{
+		inputParser:   stepconf.NewInputParser(envRepository),
+		envRepository: envRepository,
+		cmdFactory:    command.NewFactory(envRepository),
+		logger:        log.NewLogger(),
+	}
+
+	config, err := step.ProcessConfig()
+	if err != nil {
+		return e2eRun{envs: envRepository, err: err}
+	}
+
+	result, err := step.Run(config)
+	if err != nil {
+		return e2eRun{envs: envRepository, err: err}
+	}
+
+	return e2eRun{envs: envRepository, result: result, err: step.Export(result, config)}
+}
+
+// contents returns the content of each file in the pipe separated path list
+func contents(t *testing.T, pathList string) []string {
+	var fileContents []string
+	for _, pth := range strings.Split(pathList, "|") {
+		content, err := ioutil.ReadFile(pth)
+		assert.NoError(t, err)
+		fileContents = append(fileContents, string(content))
+	}
+
+	return fileContents
+}
+
+func Test_E2E_MultiStageSelectionWithPaging(t *testing.T) {
+	server := fakeapi.NewServer(e2eSpec())
+
+	run := runStep(t, server, map[string]string{
+		"artifact_sources": "build\\..*",
+	})
+
+	assert.NoError(t, run.err)
+	assert.Equal(t, []string{"android app", "android mapping", "android version", "ios app", "ios version"}, contents(t, run.envs.Get("BITRISE_ARTIFACT_PATHS")))
+	// 3 artifacts of the android build on 2 pages, 2 artifacts of the ios build on 1 page
+	assert.Equal(t, 3, server.Requests(fakeapi.EndpointListArtifacts))
+	assert.Equal(t, 5, server.Requests(fakeapi.EndpointDownload))
+
+	for _, artifact := range run.result.Artifacts {
+		assert.Equal(t, "build", artifact.Stage)
+	}
+}
+
+func Test_E2E_NameCollisions(t *testing.T) {
+	server := fakeapi.NewServer(e2eSpec())
+
+	run := runStep(t, server, map[string]string{
+		"artifact_sources": "build\\..*",
+		"export_map":       "VERSIONS: .*version\\.txt",
+	})
+
+	assert.NoError(t, run.err)
+	paths := strings.Split(run.envs.Get("VERSIONS"), "|")
+	assert.Len(t, paths, 2)
+	assert.NotEqual(t, paths[0], paths[1])
+	assert.Equal(t, []string{"android version", "ios version"}, contents(t, run.envs.Get("VERSIONS")))
+	assert.Equal(t, "build-ios", filepath.Base(filepath.Dir(paths[1])))
+}
+
+func Test_E2E_ExportMap(t *testing.T) {
+	server := fakeapi.NewServer(e2eSpec())
+
+	run := runStep(t, server, map[string]string{
+		"export_map": strings.Join([]string{
+			"APPS: .*\\.apk, .*\\.ipa",
+			"IOS_VERSION(one): workflow=ios :: .*version\\.txt",
+			"REPORTS(encoding=json): stage=test :: .*\\.xml",
+		}, "\n"),
+	})
+
+	assert.NoError(t, run.err)
+	assert.Equal(t, []string{"android app", "ios app"}, contents(t, run.envs.Get("APPS")))
+	assert.Equal(t, []string{"ios version"}, contents(t, run.envs.Get("IOS_VERSION")))
+
+	var reports []string
+	assert.NoError(t, json.Unmarshal([]byte(run.envs.Get("REPORTS")), &reports))
+	assert.Len(t, reports, 1)
+	assert.Equal(t, "junit.xml", filepath.Base(reports[0]))
+	assert.Equal(t, "", run.envs.Get("BITRISE_ARTIFACT_PATHS"))
+}
+
+func Test_E2E_ListingRecoversFromRateLimiting(t *testing.T) {
+	if testing.Short() {
+		t.Skip("the API client waits between the retries")
+	}
+
+	server := fakeapi.NewServer(e2eSpec())
+	server.AddFault(fakeapi.TooManyRequests(fakeapi.EndpointListArtifacts, 1))
+
+	run := runStep(t, server, map[string]string{
+		"artifact_sources": "test\\..*",
+	})
+
+	assert.NoError(t, run.err)
+	assert.Equal(t, []string{"<testsuite/>"}, contents(t, run.envs.Get("BITRISE_ARTIFACT_PATHS")))
+	assert.Equal(t, 2, server.Requests(fakeapi.EndpointListArtifacts))
+}
+
+func Test_E2E_DownloadFailures(t *testing.T) {
+	server := fakeapi.NewServer(e2eSpec())
+	server.AddFault(fakeapi.ExpiredURLs(0))
+
+	run := runStep(t, server, map[string]string{
+		"artifact_sources": "build\\.ios",
+	})
+
+	assert.Error(t, run.err)
+	assert.Contains(t, run.err.Error(), "Status code: 403")
+	// the signed download URL is not leaked into the error
+	assert.NotContains(t, run.err.Error(), "signature=")
+	assert.Equal(t, "", run.envs.Get("BITRISE_ARTIFACT_PATHS"))
+}
+
+func Test_E2E_DownloadRecoversFromServerError(t *testing.T) {
+	server := fakeapi.NewServer(e2eSpec())
+	server.AddFault(fakeapi.ServerError(fakeapi.EndpointDownload, 503, 1))
+
+	run := runStep(t, server, map[string]string{
+		"artifact_sources":        "build\\.ios",
+		"download_retry_wait_max": "10ms",
+	})
+
+	assert.NoError(t, run.err)
+	assert.Equal(t, []string{"ios app", "ios version"}, contents(t, run.envs.Get("BITRISE_ARTIFACT_PATHS")))
+	// the failed download is retried once
+	assert.Equal(t, 3, server.Requests(fakeapi.EndpointDownload))
+}
+
+func Test_E2E_TruncatedDownload(t *testing.T) {
+	server := fakeapi.NewServer(e2eSpec())
+	server.AddFault(fakeapi.TruncatedDownload("slug-app.ipa", 0))
+
+	run := runStep(t, server, map[string]string{
+		"artifact_sources": "build\\.ios",
+	})
+
+	assert.Error(t, run.err)
+	assert.Equal(t, "", run.envs.Get("BITRISE_ARTIFACT_PATHS"))
+}
+
+func Test_E2E_RecordAndReplay(t *testing.T) {
+	bundlePath := filepath.Join(t.TempDir(), "bundle.json")
+
+	server := fakeapi.NewServer(e2eSpec())
+	recorded := runStep(t, server, map[string]string{
+		"artifact_sources":  "build\\.ios",
+		"api_record_bundle": bundlePath,
+	})
+	assert.NoError(t, recorded.err)
+	requests := server.Requests(fakeapi.EndpointAny)
+
+	bundle, err := ioutil.ReadFile(bundlePath)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(bundle), "Bearer token")
+	// the artifacts are recorded next to the bundle, not in it
+	assert.NotContains(t, string(bundle), "ios app")
+
+	replayServer := fakeapi.NewServer(e2eSpec())
+	replayed := runStep(t, replayServer, map[string]string{
+		"artifact_sources":  "build\\.ios",
+		"finished_stage":    "",
+		"api_replay_bundle": bundlePath,
+	})
+	assert.NoError(t, replayed.err)
+	assert.Equal(t, 0, replayServer.Requests(fakeapi.EndpointAny))
+	assert.Equal(t, requests, server.Requests(fakeapi.EndpointAny))
+	assert.Equal(t, contents(t, recorded.envs.Get("BITRISE_ARTIFACT_PATHS")), contents(t, replayed.envs.Get("BITRISE_ARTIFACT_PATHS")))
+}
+
+func Test_E2E_SummaryJSON(t *testing.T) {
+	summaryPath := filepath.Join(t.TempDir(), "summary.json")
+	server := fakeapi.NewServer(e2eSpec())
+
+	run := runStep(t, server, map[string]string{
+		"artifact_sources":  "build\\.ios",
+		"summary_json_path": summaryPath,
+	})
+	assert.NoError(t, run.err)
+
+	content, err := ioutil.ReadFile(summaryPath)
+	assert.NoError(t, err)
+
+	var report struct {
+		Artifacts []struct {
+			Title    string `json:"title"`
+			Workflow string `json:"workflow"`
+			Size     int64  `json:"size_bytes"`
+			Status   string `json:"status"`
+		} `json:"artifacts"`
+		Phases []struct {
+			Name string `json:"name"`
+		} `json:"phases"`
+	}
+	assert.NoError(t, json.Unmarshal(content, &report))
+
+	assert.Len(t, report.Artifacts, 2)
+	assert.Equal(t, "app.ipa", report.Artifacts[0].Title)
+	assert.Equal(t, "ios", report.Artifacts[0].Workflow)
+	assert.Equal(t, int64(len("ios app")), report.Artifacts[0].Size)
+	assert.Equal(t, "downloaded", report.Artifacts[0].Status)
+
+	var phases []string
+	for _, phase := range report.Phases {
+		phases = append(phases, phase.Name)
+	}
+	assert.Equal(t, []string{"build selection", "listing", "download", "export"}, phases)
+}
+
+func Test_E2E_StepTimeout(t *testing.T) {
+	server := fakeapi.NewServer(e2eSpec())
+	server.AddFault(fakeapi.Latency(fakeapi.EndpointDownload, time.Second))
+
+	run := runStep(t, server, map[string]string{
+		"artifact_sources": "build\\.ios",
+		"download_threads": "1",
+		"step_timeout":     "200ms",
+	})
+
+	assert.Error(t, run.err)
+	assert.Contains(t, run.err.Error(), "the step did not finish within the step_timeout (200ms)")
+	assert.Equal(t, "", run.envs.Get("BITRISE_ARTIFACT_PATHS"))
+}
@@ -0,0 +1,67 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/model"
+)
+
+const (
+	// CardinalityAny exports every matched file
+	CardinalityAny = ""
+	// CardinalityExactlyOne fails unless exactly one file matches
+	CardinalityExactlyOne = "one"
+	// CardinalityAtLeastOne fails if no file matches
+	CardinalityAtLeastOne = "at-least-one"
+	// CardinalityAtMost fails if more than Max files match
+	CardinalityAtMost = "at-most"
+	// CardinalityFirst exports the first matched file only
+	CardinalityFirst = "first"
+)
+
+// Cardinality defines the number of files an export map entry expects to match
+type Cardinality struct {
+	Kind string
+	Max  int
+}
+
+func (c Cardinality) String() string {
+	if c.Kind == CardinalityAtMost {
+		return fmt.Sprintf("%s=%d", c.Kind, c.Max)
+	}
+
+	return c.Kind
+}
+
+// apply checks the number of the matched files, and returns the files to export
+func (c Cardinality) apply(variable string, matched []model.PulledArtifact) ([]model.PulledArtifact, error) {
+	switch c.Kind {
+	case CardinalityExactlyOne:
+		if len(matched) != 1 {
+			return nil, cardinalityError(variable, "exactly one file should match", matched)
+		}
+	case CardinalityAtLeastOne:
+		if len(matched) == 0 {
+			return nil, cardinalityError(variable, "at least one file should match", matched)
+		}
+	case CardinalityAtMost:
+		if len(matched) > c.Max {
+			return nil, cardinalityError(variable, fmt.Sprintf("at most %d file(s) should match", c.Max), matched)
+		}
+	case CardinalityFirst:
+		if len(matched) > 1 {
+			return matched[:1], nil
+		}
+	}
+
+	return matched, nil
+}
+
+func cardinalityError(variable, expectation string, matched []model.PulledArtifact) error {
+	if len(matched) == 0 {
+		return fmt.Errorf("%s: %s, but none matched", variable, expectation)
+	}
+
+	return fmt.Errorf("%s: %s, but %d matched: %s", variable, expectation, len(matched), strings.Join(artifactPaths(matched), ", "))
+}
@@ -0,0 +1,87 @@
+package export
+
+import (
+	"testing"
+
+	mockenv "github.com/bitrise-io/go-utils/env/mocks"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatternBasedOutputExport_Cardinality(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		exportMap     string
+		artifacts     []string
+		expectedValue string
+		expectedError string
+	}{
+		{
+			desc:          "when exactly one file matches a single value rule",
+			exportMap:     `APK(one): .*\.apk`,
+			artifacts:     []string{"a.apk", "a.ipa"},
+			expectedValue: "a.apk",
+		},
+		{
+			desc:          "when multiple files match a single value rule",
+			exportMap:     `APK(one): .*\.apk`,
+			artifacts:     []string{"a.apk", "b.apk"},
+			expectedError: "APK: exactly one file should match, but 2 matched: a.apk, b.apk",
+		},
+		{
+			desc:          "when no file matches a single value rule",
+			exportMap:     `APK(one): .*\.apk`,
+			artifacts:     []string{"a.ipa"},
+			expectedError: "APK: exactly one file should match, but none matched",
+		},
+		{
+			desc:          "when no file matches an at least one rule",
+			exportMap:     `APKS(at-least-one): .*\.apk`,
+			artifacts:     []string{"a.ipa"},
+			expectedError: "APKS: at least one file should match, but none matched",
+		},
+		{
+			desc:          "when the files match an at least one rule",
+			exportMap:     `APKS(at-least-one): .*\.apk`,
+			artifacts:     []string{"a.apk", "b.apk"},
+			expectedValue: "a.apk|b.apk",
+		},
+		{
+			desc:          "when too many files match an at most rule",
+			exportMap:     `APKS(at-most=2): .*\.apk`,
+			artifacts:     []string{"a.apk", "b.apk", "c.apk"},
+			expectedError: "APKS: at most 2 file(s) should match, but 3 matched: a.apk, b.apk, c.apk",
+		},
+		{
+			desc:          "when multiple files match a first match rule",
+			exportMap:     `APK(first): .*\.apk`,
+			artifacts:     []string{"a.apk", "b.apk"},
+			expectedValue: "a.apk",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			envRepository := new(mockenv.Repository)
+			rules := exportRules(t, tC.exportMap)
+			if tC.expectedValue != "" {
+				envRepository.On("Set", rules[0].Variable, tC.expectedValue).Return(nil)
+			}
+
+			exporter := OutputExporter{
+				Artifacts:     pulledArtifacts(tC.artifacts...),
+				Logger:        log.NewLogger(),
+				EnvRepository: envRepository,
+				Rules:         rules,
+			}
+
+			err := exporter.Export()
+			if tC.expectedError != "" {
+				assert.EqualError(t, err, tC.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			envRepository.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package export
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/model"
+)
+
+// DeployDirExporter places the selected pulled artifacts into the deploy directory, so that the deploy step attaches
+// them to the build
+type DeployDirExporter struct {
+	Selection []Rule
+	Artifacts []model.PulledArtifact
+	DeployDir string
+	Logger    log.Logger
+}
+
+// Deploy hardlinks (or if it is not possible, copies) the selected artifacts into the deploy directory and returns the
+// paths of the placed files
+func (de DeployDirExporter) Deploy() ([]string, error) {
+	selected := SelectArtifacts(de.Selection, de.Artifacts)
+
+	if len(selected) == 0 {
+		de.Logger.Warnf("None of the pulled artifacts is selected for the deploy directory")
+		return nil, nil
+	}
+
+	if err := os.MkdirAll(de.DeployDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create the deploy directory: %w", err)
+	}
+
+	var placed []string
+	used := make(map[string]bool)
+	for _, artifact := range selected {
+		name := deployFileName(artifact, func(name string) bool {
+			if used[name] {
+				return true
+			}
+			_, err := os.Lstat(filepath.Join(de.DeployDir, name))
+			return err == nil
+		})
+		used[name] = true
+
+		destination := filepath.Join(de.DeployDir, name)
+		if err := placeFile(artifact.Path, destination, DestinationModeHardlink); err != nil {
+			return nil, fmt.Errorf("failed to place %s to the deploy directory: %w", artifact.Path, err)
+		}
+		de.Logger.Printf("%s is placed to %s", artifact.Path, destination)
+
+		placed = append(placed, destination)
+	}
+
+	return placed, nil
+}
+
+// deployFileName returns the first name which is not taken yet from: the file name, the file name prefixed with the
+// stage and workflow, the file name prefixed with the build slug, and the file name with a numbered suffix
+func deployFileName(artifact model.PulledArtifact, taken func(name string) bool) string {
+	filename := filepath.Base(artifact.Path)
+
+	candidates := []string{filename}
+	if artifact.Stage != "" && artifact.Workflow != "" {
+		candidates = append(candidates, safeFileName(artifact.Stage)+"_"+safeFileName(artifact.Workflow)+"_"+filename)
+	}
+	if artifact.BuildSlug != "" {
+		candidates = append(candidates, safeFileName(artifact.BuildSlug)+"_"+filename)
+	}
+	for _, candidate := range candidates {
+		if !taken(candidate) {
+			return candidate
+		}
+	}
+
+	ext := filepath.Ext(filename)
+	base := strings.TrimSuffix(filename, ext)
+	for i := 2; ; i++ {
+		candidate := base + "-" + strconv.Itoa(i) + ext
+		if !taken(candidate) {
+			return candidate
+		}
+	}
+}
+
+func safeFileName(name string) string {
+	return strings.NewReplacer("/", "_", string(filepath.Separator), "_").Replace(name)
+}
@@ -0,0 +1,74 @@
+package export
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeployDirExporter_Deploy(t *testing.T) {
+	sourceDir := t.TempDir()
+	deployDir := t.TempDir()
+
+	writeFile := func(pth, content string) string {
+		assert.NoError(t, ioutil.WriteFile(pth, []byte(content), 0o644))
+		return pth
+	}
+	writeFile(filepath.Join(deployDir, "existing.txt"), "existing")
+	assert.NoError(t, os.Mkdir(filepath.Join(sourceDir, "sub"), 0o755))
+
+	artifacts := []model.PulledArtifact{
+		{Path: writeFile(filepath.Join(sourceDir, "app.apk"), "android"), BuildSlug: "build-1", Stage: "build", Workflow: "android"},
+		{Path: writeFile(filepath.Join(sourceDir, "sub", "app.apk"), "android-2"), BuildSlug: "build-2", Stage: "build", Workflow: "android-2"},
+		{Path: writeFile(filepath.Join(sourceDir, "existing.txt"), "pulled"), BuildSlug: "build-1", Stage: "build", Workflow: "android"},
+		{Path: writeFile(filepath.Join(sourceDir, "app.ipa"), "ios"), BuildSlug: "build-3", Stage: "build", Workflow: "ios"},
+	}
+
+	exporter := DeployDirExporter{
+		Selection: exportSelection(t, ".*\\.apk\nworkflow=android :: .*\\.txt"),
+		Artifacts: artifacts,
+		DeployDir: deployDir,
+		Logger:    log.NewLogger(),
+	}
+
+	placed, err := exporter.Deploy()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(deployDir, "app.apk"),
+		filepath.Join(deployDir, "build_android-2_app.apk"),
+		filepath.Join(deployDir, "build_android_existing.txt"),
+	}, placed)
+
+	for i, pth := range placed {
+		expected, err := ioutil.ReadFile(artifacts[i].Path)
+		assert.NoError(t, err)
+		content, err := ioutil.ReadFile(pth)
+		assert.NoError(t, err)
+		assert.Equal(t, string(expected), string(content))
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(deployDir, "existing.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "existing", string(content))
+}
+
+func TestDeployFileName(t *testing.T) {
+	taken := map[string]bool{"app.apk": true, "build_android_app.apk": true, "build-1_app.apk": true, "app-2.apk": true}
+	isTaken := func(name string) bool { return taken[name] }
+
+	assert.Equal(t, "app.ipa", deployFileName(model.PulledArtifact{Path: "/tmp/app.ipa"}, isTaken))
+	assert.Equal(t, "build_ios_app.apk", deployFileName(model.PulledArtifact{Path: "/tmp/app.apk", Stage: "build", Workflow: "ios"}, isTaken))
+	assert.Equal(t, "app-3.apk", deployFileName(model.PulledArtifact{Path: "/tmp/app.apk", Stage: "build", Workflow: "android", BuildSlug: "build-1"}, isTaken))
+}
+
+func exportSelection(t *testing.T, raw string) []Rule {
+	rules, err := ParseSelection("selection", raw)
+	assert.NoError(t, err)
+
+	return rules
+}
@@ -0,0 +1,148 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/model"
+)
+
+const (
+	DestinationModeCopy     = "copy"
+	DestinationModeHardlink = "hardlink"
+	DestinationModeSymlink  = "symlink"
+)
+
+// destinationPlaceholders are replaced with the properties of the placed artifact in the destination templates
+var destinationPlaceholders = []string{"{filename}", "{title}", "{stage}", "{workflow}", "{build_slug}"}
+
+// placeFiles places the matched files to the destination of the rule, and returns the destination paths
+func (oe OutputExporter) placeFiles(rule Rule, artifacts []model.PulledArtifact) ([]string, error) {
+	if len(artifacts) > 1 && !hasPlaceholder(rule.Destination) {
+		return nil, fmt.Errorf("%s: the single destination %s matches multiple files: %s", rule.Variable, rule.Destination, strings.Join(artifactPaths(artifacts), ", "))
+	}
+
+	var destinations []string
+	sources := make(map[string]string)
+	for _, artifact := range artifacts {
+		destination := oe.resolveDestination(rule.Destination, artifact)
+		if source, ok := sources[destination]; ok {
+			return nil, fmt.Errorf("%s: %s and %s are placed to the same destination: %s", rule.Variable, source, artifact.Path, destination)
+		}
+		sources[destination] = artifact.Path
+
+		destinations = append(destinations, destination)
+	}
+
+	for i, artifact := range artifacts {
+		if err := placeFile(artifact.Path, destinations[i], oe.DestinationMode); err != nil {
+			return nil, fmt.Errorf("%s: failed to place %s to %s: %w", rule.Variable, artifact.Path, destinations[i], err)
+		}
+		oe.Logger.Debugf("%s is placed to %s", artifact.Path, destinations[i])
+	}
+
+	return destinations, nil
+}
+
+func hasPlaceholder(template string) bool {
+	for _, placeholder := range destinationPlaceholders {
+		if strings.Contains(template, placeholder) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolveDestination expands the environment variables and the placeholders of the destination template
+func (oe OutputExporter) resolveDestination(template string, artifact model.PulledArtifact) string {
+	destination := os.Expand(template, oe.EnvRepository.Get)
+
+	title := artifact.Title
+	if title == "" {
+		title = filepath.Base(artifact.Path)
+	}
+	destination = strings.NewReplacer(
+		"{filename}", filepath.Base(artifact.Path),
+		"{title}", title,
+		"{stage}", artifact.Stage,
+		"{workflow}", artifact.Workflow,
+		"{build_slug}", artifact.BuildSlug,
+	).Replace(destination)
+
+	if absDestination, err := filepath.Abs(destination); err == nil {
+		destination = absDestination
+	}
+
+	return destination
+}
+
+func placeFile(source, destination, mode string) error {
+	if err := os.MkdirAll(filepath.Dir(destination), 0o755); err != nil {
+		return err
+	}
+
+	// the destination can already be the source (or a link to it), removing it would delete the source
+	sourceInfo, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	if destinationInfo, err := os.Stat(destination); err == nil && os.SameFile(sourceInfo, destinationInfo) {
+		return nil
+	}
+
+	if err := os.Remove(destination); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	switch mode {
+	case DestinationModeSymlink:
+		absSource, err := filepath.Abs(source)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(absSource, destination)
+	case DestinationModeHardlink:
+		if err := os.Link(source, destination); err == nil {
+			return nil
+		}
+		// hard links do not work across file systems, fall back to copying
+		return copyFile(source, destination)
+	default:
+		return copyFile(source, destination)
+	}
+}
+
+func copyFile(source, destination string) (err error) {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := in.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(destination, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	_, err = io.Copy(out, in)
+
+	return err
+}
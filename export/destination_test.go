@@ -0,0 +1,131 @@
+package export
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	mockenv "github.com/bitrise-io/go-utils/env/mocks"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func createArtifactFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0o644))
+
+	return path
+}
+
+func TestPatternBasedOutputExport_Destination_Copy(t *testing.T) {
+	downloadDir := t.TempDir()
+	distDir := t.TempDir()
+	ipaPath := createArtifactFile(t, downloadDir, "app.ipa", "ipa")
+	expectedDestination := filepath.Join(distDir, "dist", "app.ipa")
+
+	envRepository := new(mockenv.Repository)
+	envRepository.On("Get", "SOURCE_DIR").Return(distDir)
+	envRepository.On("Set", "APP_IPA", expectedDestination).Return(nil)
+
+	exporter := OutputExporter{
+		Artifacts:       []model.PulledArtifact{{Path: ipaPath}, {Path: createArtifactFile(t, downloadDir, "app.apk", "apk")}},
+		DestinationMode: DestinationModeCopy,
+		Logger:          log.NewLogger(),
+		EnvRepository:   envRepository,
+		Rules:           exportRules(t, `APP_IPA: .*\.ipa -> $SOURCE_DIR/dist/app.ipa`),
+	}
+
+	err := exporter.Export()
+	assert.NoError(t, err)
+
+	content, err := ioutil.ReadFile(expectedDestination)
+	assert.NoError(t, err)
+	assert.Equal(t, "ipa", string(content))
+	envRepository.AssertExpectations(t)
+}
+
+func TestPatternBasedOutputExport_Destination_SymlinkTemplate(t *testing.T) {
+	downloadDir := t.TempDir()
+	distDir := t.TempDir()
+	androidAPK := createArtifactFile(t, downloadDir, "android/app.apk", "android")
+	wearAPK := createArtifactFile(t, downloadDir, "wear/app.apk", "wear")
+	androidDestination := filepath.Join(distDir, "android-app.apk")
+	wearDestination := filepath.Join(distDir, "wear-app.apk")
+
+	envRepository := new(mockenv.Repository)
+	envRepository.On("Set", "APKS", androidDestination+"|"+wearDestination).Return(nil)
+
+	exporter := OutputExporter{
+		Artifacts: []model.PulledArtifact{
+			{Path: androidAPK, Workflow: "android"},
+			{Path: wearAPK, Workflow: "wear"},
+		},
+		DestinationMode: DestinationModeSymlink,
+		Logger:          log.NewLogger(),
+		EnvRepository:   envRepository,
+		Rules:           exportRules(t, `APKS: .*\.apk -> "`+distDir+`/{workflow}-{filename}"`),
+	}
+
+	err := exporter.Export()
+	assert.NoError(t, err)
+
+	target, err := os.Readlink(wearDestination)
+	assert.NoError(t, err)
+	assert.Equal(t, wearAPK, target)
+	envRepository.AssertExpectations(t)
+}
+
+func TestPatternBasedOutputExport_Destination_MultipleMatches(t *testing.T) {
+	exporter := OutputExporter{
+		Artifacts:     pulledArtifacts("/tmp/a.ipa", "/tmp/b.ipa"),
+		Logger:        log.NewLogger(),
+		EnvRepository: new(mockenv.Repository),
+		Rules:         exportRules(t, `APP_IPA: .*\.ipa -> /dist/app.ipa`),
+	}
+
+	err := exporter.Export()
+	assert.EqualError(t, err, "APP_IPA: the single destination /dist/app.ipa matches multiple files: /tmp/a.ipa, /tmp/b.ipa")
+}
+
+func TestPatternBasedOutputExport_Destination_Collision(t *testing.T) {
+	exporter := OutputExporter{
+		Artifacts:     pulledArtifacts("/tmp/a/app.ipa", "/tmp/b/app.ipa"),
+		Logger:        log.NewLogger(),
+		EnvRepository: new(mockenv.Repository),
+		Rules:         exportRules(t, `APP_IPA: .*\.ipa -> /dist/{filename}`),
+	}
+
+	err := exporter.Export()
+	assert.EqualError(t, err, "APP_IPA: /tmp/a/app.ipa and /tmp/b/app.ipa are placed to the same destination: /dist/app.ipa")
+}
+
+func TestPatternBasedOutputExport_Destination_SameFile(t *testing.T) {
+	downloadDir := t.TempDir()
+	ipaPath := createArtifactFile(t, downloadDir, "app.ipa", "ipa")
+
+	for _, mode := range []string{DestinationModeCopy, DestinationModeHardlink, DestinationModeSymlink} {
+		t.Run(mode, func(t *testing.T) {
+			envRepository := new(mockenv.Repository)
+			envRepository.On("Set", "APP_IPA", ipaPath).Return(nil)
+
+			exporter := OutputExporter{
+				Artifacts:       []model.PulledArtifact{{Path: ipaPath}},
+				DestinationMode: mode,
+				Logger:          log.NewLogger(),
+				EnvRepository:   envRepository,
+				Rules:           exportRules(t, `APP_IPA: .*\.ipa -> "`+ipaPath+`"`),
+			}
+
+			err := exporter.Export()
+			assert.NoError(t, err)
+
+			content, err := ioutil.ReadFile(ipaPath)
+			assert.NoError(t, err)
+			assert.Equal(t, "ipa", string(content))
+			envRepository.AssertExpectations(t)
+		})
+	}
+}
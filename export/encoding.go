@@ -0,0 +1,93 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// EncodingPipe joins the paths with pipe (|) characters
+	EncodingPipe = "pipe"
+	// EncodingNewline joins the paths with new line characters
+	EncodingNewline = "newline"
+	// EncodingJSON encodes the paths as a JSON array
+	EncodingJSON = "json"
+	// EncodingFile writes the paths to a file (one path per line) and exports the path of the file
+	EncodingFile = "file"
+
+	// envValueSizeLimit is a conservative size limit of an exported value chosen by this step,
+	// longer path lists are written to a file instead
+	envValueSizeLimit = 20 * 1024
+)
+
+func isEncoding(encoding string) bool {
+	switch encoding {
+	case EncodingPipe, EncodingNewline, EncodingJSON, EncodingFile:
+		return true
+	default:
+		return false
+	}
+}
+
+// encode returns the exported value of the paths. Values exceeding the export size limit are written to a file.
+func (oe OutputExporter) encode(variable string, paths []string, encoding string) (string, error) {
+	if encoding == "" {
+		encoding = oe.Encoding
+	}
+
+	var value string
+	switch encoding {
+	case EncodingNewline:
+		value = strings.Join(paths, "\n")
+	case EncodingJSON:
+		if paths == nil {
+			paths = []string{}
+		}
+		content, err := json.Marshal(paths)
+		if err != nil {
+			return "", err
+		}
+		value = string(content)
+	case EncodingFile:
+		return oe.writePathsFile(variable, paths)
+	default:
+		for _, path := range paths {
+			if strings.Contains(path, "|") {
+				oe.Logger.Warnf("The path %s contains a pipe (|) character, use a different export encoding for $%s", path, variable)
+			}
+		}
+		value = strings.Join(paths, "|")
+	}
+
+	if len(value) > envValueSizeLimit {
+		oe.Logger.Warnf("The value of $%s exceeds the export size limit (%d bytes), the paths are written to a file", variable, envValueSizeLimit)
+		return oe.writePathsFile(variable, paths)
+	}
+
+	return value, nil
+}
+
+func (oe OutputExporter) writePathsFile(variable string, paths []string) (string, error) {
+	dir := oe.FileDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create the directory of the exported path lists: %w", err)
+	}
+
+	pth := filepath.Join(dir, variable+".txt")
+	content := strings.Join(paths, "\n")
+	if len(paths) > 0 {
+		content += "\n"
+	}
+	if err := ioutil.WriteFile(pth, []byte(content), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write the path list of $%s: %w", variable, err)
+	}
+
+	return pth, nil
+}
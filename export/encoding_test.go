@@ -0,0 +1,102 @@
+package export
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	mockenv "github.com/bitrise-io/go-utils/env/mocks"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimpleOutputExport_Encodings(t *testing.T) {
+	fileDir := t.TempDir()
+
+	testCases := []struct {
+		encoding            string
+		expectedValue       string
+		expectedFileContent string
+	}{
+		{encoding: EncodingPipe, expectedValue: "a b.txt|c|d.txt"},
+		{encoding: EncodingNewline, expectedValue: "a b.txt\nc|d.txt"},
+		{encoding: EncodingJSON, expectedValue: `["a b.txt","c|d.txt"]`},
+		{encoding: EncodingFile, expectedValue: filepath.Join(fileDir, "BITRISE_ARTIFACT_PATHS.txt"), expectedFileContent: "a b.txt\nc|d.txt\n"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.encoding, func(t *testing.T) {
+			envRepository := new(mockenv.Repository)
+			envRepository.On("Set", "BITRISE_ARTIFACT_PATHS", tC.expectedValue).Return(nil)
+
+			exporter := OutputExporter{
+				Artifacts:     pulledArtifacts("a b.txt", "c|d.txt"),
+				Encoding:      tC.encoding,
+				FileDir:       fileDir,
+				Logger:        log.NewLogger(),
+				EnvRepository: envRepository,
+			}
+
+			err := exporter.Export()
+			assert.NoError(t, err)
+
+			if tC.expectedFileContent != "" {
+				content, err := ioutil.ReadFile(tC.expectedValue)
+				assert.NoError(t, err)
+				assert.Equal(t, tC.expectedFileContent, string(content))
+			}
+			envRepository.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPatternBasedOutputExport_RuleEncodingOverridesGlobalEncoding(t *testing.T) {
+	envRepository := new(mockenv.Repository)
+	envRepository.On("Set", "APKS", `["a.apk","b.apk"]`).Return(nil)
+	envRepository.On("Set", "TXTS", "a.txt\nb.txt").Return(nil)
+
+	exporter := OutputExporter{
+		Artifacts:     pulledArtifacts("a.apk", "b.apk", "a.txt", "b.txt"),
+		Encoding:      EncodingNewline,
+		Logger:        log.NewLogger(),
+		EnvRepository: envRepository,
+		Rules: exportRules(t, `
+APKS(encoding=json): .*\.apk
+TXTS: .*\.txt
+`),
+	}
+
+	err := exporter.Export()
+	assert.NoError(t, err)
+
+	envRepository.AssertExpectations(t)
+}
+
+func TestSimpleOutputExport_FallsBackToFileWhenValueIsTooLarge(t *testing.T) {
+	fileDir := t.TempDir()
+	var paths []string
+	for i := 0; i < 1000; i++ {
+		paths = append(paths, fmt.Sprintf("/tmp/_artifact_pull/some/long/directory/artifact_%d.txt", i))
+	}
+	expectedFile := filepath.Join(fileDir, "BITRISE_ARTIFACT_PATHS.txt")
+
+	envRepository := new(mockenv.Repository)
+	envRepository.On("Set", "BITRISE_ARTIFACT_PATHS", expectedFile).Return(nil)
+
+	exporter := OutputExporter{
+		Artifacts:     pulledArtifacts(paths...),
+		Encoding:      EncodingPipe,
+		FileDir:       fileDir,
+		Logger:        log.NewLogger(),
+		EnvRepository: envRepository,
+	}
+
+	err := exporter.Export()
+	assert.NoError(t, err)
+
+	content, err := ioutil.ReadFile(expectedFile)
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Join(paths, "\n")+"\n", string(content))
+	envRepository.AssertExpectations(t)
+}
@@ -2,106 +2,127 @@ package export
 
 import (
 	"fmt"
-	"regexp"
-	"strings"
 
 	"github.com/bitrise-io/go-utils/env"
 	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/model"
 )
 
 type OutputExporter struct {
-	ExportPattern map[string]string
-	ExportValues  string
+	Rules     []Rule
+	Artifacts []model.PulledArtifact
+	// DestinationMode defines how the matched files are placed to the destination of the rules (copy, hardlink or symlink)
+	DestinationMode string
+	// Encoding is the encoding of the exported path lists, unless a rule defines its own
+	Encoding string
+	// FileDir is the directory of the path list files exported with the file encoding
+	FileDir string
+	// GroupedPaths enables exporting the paths of the artifacts per source stage and per source workflow
+	GroupedPaths  bool
 	Logger        log.Logger
 	EnvRepository env.Repository
 }
 
-func ProcessRawExportMap(rawMap string) map[string]string {
-	res := make(map[string]string)
-	rawExportMapArray := strings.Split(strings.TrimSpace(rawMap), "\n")
-	for _, line := range rawExportMapArray {
-		if len(strings.TrimSpace(line)) > 0 {
-			parsedLine := strings.Split(line, ":")
-
-			variableName := strings.TrimSpace(parsedLine[0])
-			patternValue := strings.TrimSpace(parsedLine[1])
-			if len(parsedLine) != 2 ||
-				len(variableName) == 0 ||
-				len(patternValue) == 0 {
-				continue
-			}
+func (oe OutputExporter) Export() error {
+	var err error
+	if len(oe.Rules) == 0 {
+		err = oe.simpleOutputExport()
+	} else {
+		err = oe.patternBasedOutputExport()
+	}
+	if err != nil {
+		return err
+	}
 
-			res[variableName] = patternValue
-		}
+	if oe.GroupedPaths {
+		return oe.groupedOutputExport()
 	}
 
-	return res
+	return nil
 }
 
-func (oe OutputExporter) Export() error {
-	if len(oe.ExportPattern) == 0 {
-		return oe.simpleOutputExport()
+func (oe OutputExporter) simpleOutputExport() error {
+	exportValue, err := oe.encode("BITRISE_ARTIFACT_PATHS", artifactPaths(oe.Artifacts), "")
+	if err != nil {
+		return err
 	}
-	return oe.patternBasedOutputExport()
-}
 
-func (oe OutputExporter) simpleOutputExport() error {
-	err := oe.exportOutputVariable("BITRISE_ARTIFACT_PATHS", oe.ExportValues)
+	err = oe.exportOutputVariable("BITRISE_ARTIFACT_PATHS", exportValue)
 	if err != nil {
 		return err
 	}
 
 	oe.Logger.Println()
 	oe.Logger.Printf("The following outputs are exported as environment variables:")
-	oe.Logger.Printf("$BITRISE_ARTIFACT_PATHS = %s", oe.ExportValues)
+	oe.Logger.Printf("$BITRISE_ARTIFACT_PATHS = %s", exportValue)
 
 	return nil
 }
 
 func (oe OutputExporter) patternBasedOutputExport() error {
-	filePaths := strings.Split(oe.ExportValues, "|")
-
 	exportMap := make(map[string][]string)
 
-	for k, v := range oe.ExportPattern {
-		for _, filePath := range filePaths {
-			valueExpressions := strings.Split(v, ",")
-
-			for _, expression := range valueExpressions {
-				matched, err :=  regexp.MatchString(expression, filePath)
-				if err != nil {
-					return err
-				}
-
-				if matched {
-					if el := exportMap[k]; el != nil {
-						el = append(el, filePath)
-						exportMap[k] = el
-					} else {
-						exportMap[k] = []string{filePath}
-					}
-				}
+	for _, rule := range oe.Rules {
+		var matched []model.PulledArtifact
+		for _, artifact := range oe.Artifacts {
+			if rule.match(artifact) {
+				matched = append(matched, artifact)
 			}
 		}
+
+		matched, err := rule.Cardinality.apply(rule.Variable, matched)
+		if err != nil {
+			return err
+		}
+		if len(matched) == 0 {
+			continue
+		}
+
+		if rule.Destination == "" {
+			exportMap[rule.Variable] = artifactPaths(matched)
+			continue
+		}
+
+		placedPaths, err := oe.placeFiles(rule, matched)
+		if err != nil {
+			return err
+		}
+		exportMap[rule.Variable] = placedPaths
 	}
 
 	oe.Logger.Println()
 	oe.Logger.Printf("\nThe following outputs are exported as environment variables:")
 
-	for k, v := range exportMap {
-		exportVal := strings.Join(v, "|")
+	for _, rule := range oe.Rules {
+		v, ok := exportMap[rule.Variable]
+		if !ok {
+			continue
+		}
+		exportVal, err := oe.encode(rule.Variable, v, rule.Encoding)
+		if err != nil {
+			return err
+		}
 
-		err := oe.exportOutputVariable(k, exportVal)
+		err = oe.exportOutputVariable(rule.Variable, exportVal)
 		if err != nil {
 			return err
 		}
 
-		oe.Logger.Printf("$%s = %s", k, exportVal)
+		oe.Logger.Printf("$%s = %s", rule.Variable, exportVal)
 	}
 
 	return nil
 }
 
+func artifactPaths(artifacts []model.PulledArtifact) []string {
+	var paths []string
+	for _, artifact := range artifacts {
+		paths = append(paths, artifact.Path)
+	}
+
+	return paths
+}
+
 func (oe OutputExporter) exportOutputVariable(key string, value string) error {
 	if err := oe.EnvRepository.Set(key, value); err != nil {
 		return fmt.Errorf("failed to export pulled artifact locations, error: %s", err)
@@ -0,0 +1,480 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/model"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/selector"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// sourceSeparator separates the optional source selector (like `stage=build workflow=android`) from the file path patterns of an export map entry
+	sourceSeparator = "::"
+	// destinationSeparator separates the file path patterns from the optional destination path of an export map entry
+	destinationSeparator = "->"
+	// yamlDocumentMarker marks an export map in the YAML format explicitly
+	yamlDocumentMarker = "---"
+)
+
+// errNoPattern is returned for the entries without any pattern, these entries are skipped with a warning
+var errNoPattern = errors.New("no pattern is defined")
+
+// Rule is a parsed export map entry: the paths of the pulled artifacts matching any of the patterns are exported to the variable
+type Rule struct {
+	Variable string
+	// Source limits the rule to the artifacts of the matching stages and workflows, nil means any source
+	Source   selector.Selector
+	Patterns []*regexp.Regexp
+	// Destination is an optional path template, the matched files are placed there and the destination paths are exported
+	Destination string
+	Cardinality Cardinality
+	// Encoding overrides the export encoding of the path list if it is not empty
+	Encoding string
+	// Line is the line of the entry in the raw export map, used in error messages
+	Line int
+}
+
+func (r Rule) match(artifact model.PulledArtifact) bool {
+	if r.Source != nil && !r.Source.Match(artifact.Stage, artifact.Workflow) {
+		return false
+	}
+
+	for _, pattern := range r.Patterns {
+		if pattern.MatchString(artifact.Path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseExportMap parses the export_map input. Supported formats:
+//
+//   - one `VARIABLE: [source selector ::] pattern1, pattern2` entry per line, patterns containing commas or `::` can be
+//     quoted with single or double quotes (`\"` escapes a double quote within double quotes),
+//   - a JSON object, where the values are either entries in the line format or arrays of patterns,
+//   - a YAML mapping, with the same values as the JSON object. It is detected by a leading `---` line, or by a first
+//     entry without an inline value, followed by an indented block (like a list of patterns).
+//
+// Entries without patterns are skipped and a redefined variable replaces the earlier entry, both with a warning.
+func ParseExportMap(raw string, logger log.Logger) ([]Rule, error) {
+	trimmed := strings.TrimSpace(raw)
+
+	var entries []rawRule
+	var err error
+	switch {
+	case trimmed == "":
+		return nil, nil
+	case strings.HasPrefix(trimmed, "{"):
+		entries, err = parseJSONExportMap(raw)
+	case isYAMLExportMap(raw):
+		entries, err = parseYAMLExportMap(raw)
+	default:
+		entries, err = parseLineExportMap(raw)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newRules(entries, logger)
+}
+
+type exportMapError struct {
+	line int
+	msg  string
+}
+
+func (e exportMapError) Error() string {
+	return fmt.Sprintf("invalid export map, line %d: %s", e.line, e.msg)
+}
+
+func newExportMapError(line int, format string, v ...interface{}) error {
+	return exportMapError{line: line, msg: fmt.Sprintf(format, v...)}
+}
+
+func parseLineExportMap(raw string) ([]rawRule, error) {
+	var entries []rawRule
+	for i, line := range strings.Split(raw, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		split := strings.SplitN(line, ":", 2)
+		if len(split) != 2 {
+			return nil, newExportMapError(i+1, "missing ':' separator after the variable name: %s", line)
+		}
+
+		entries = append(entries, rawRule{line: i + 1, variable: strings.TrimSpace(split[0]), value: strings.TrimSpace(split[1])})
+	}
+
+	return entries, nil
+}
+
+func parseJSONExportMap(raw string) ([]rawRule, error) {
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	lineAt := func() int {
+		return bytes.Count([]byte(raw[:decoder.InputOffset()]), []byte("\n")) + 1
+	}
+
+	if tok, err := decoder.Token(); err != nil || tok != json.Delim('{') {
+		return nil, newExportMapError(lineAt(), "a JSON object is expected")
+	}
+
+	var entries []rawRule
+	for decoder.More() {
+		tok, err := decoder.Token()
+		if err != nil {
+			return nil, newExportMapError(lineAt(), "invalid JSON: %s", err)
+		}
+		variable, ok := tok.(string)
+		if !ok {
+			return nil, newExportMapError(lineAt(), "invalid JSON: variable name expected")
+		}
+		line := lineAt()
+
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, newExportMapError(lineAt(), "invalid JSON: %s", err)
+		}
+
+		entry := rawRule{line: line, variable: variable}
+		if err := json.Unmarshal(value, &entry.value); err != nil {
+			if err := json.Unmarshal(value, &entry.patterns); err != nil {
+				return nil, newExportMapError(line, "the value of %s should be a string or an array of strings", variable)
+			}
+			entry.isList = true
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return nil, newExportMapError(lineAt(), "invalid JSON: %s", err)
+	}
+
+	return entries, nil
+}
+
+// isYAMLExportMap reports whether the export map starts with the YAML document marker, or with an entry without an
+// inline value which is followed by an indented or list item line. Only the first entry is checked, so an indented
+// line later in a line format export map does not change its format.
+func isYAMLExportMap(raw string) bool {
+	var lines []string
+	for _, line := range strings.Split(raw, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return false
+	}
+
+	first := strings.TrimSpace(lines[0])
+	if first == yamlDocumentMarker {
+		return true
+	}
+	if !strings.HasSuffix(first, ":") || strings.Count(first, ":") != 1 || len(lines) < 2 {
+		return false
+	}
+
+	next := lines[1]
+
+	return strings.HasPrefix(next, " ") || strings.HasPrefix(next, "\t") || strings.HasPrefix(next, "-")
+}
+
+func parseYAMLExportMap(raw string) ([]rawRule, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(raw), &document); err != nil {
+		return nil, fmt.Errorf("invalid export map: %w", err)
+	}
+	if len(document.Content) != 1 || document.Content[0].Kind != yaml.MappingNode {
+		return nil, newExportMapError(document.Line, "a YAML mapping is expected")
+	}
+
+	var entries []rawRule
+	mapping := document.Content[0]
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		key, value := mapping.Content[i], mapping.Content[i+1]
+		entry := rawRule{line: key.Line, variable: key.Value}
+
+		switch value.Kind {
+		case yaml.ScalarNode:
+			entry.value = value.Value
+		case yaml.SequenceNode:
+			entry.isList = true
+			for _, item := range value.Content {
+				if item.Kind != yaml.ScalarNode {
+					return nil, newExportMapError(item.Line, "the patterns of %s should be strings", key.Value)
+				}
+				entry.patterns = append(entry.patterns, item.Value)
+			}
+		default:
+			return nil, newExportMapError(value.Line, "the value of %s should be a string or a list of strings", key.Value)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// rawRule is an export map entry before its patterns are compiled
+type rawRule struct {
+	line     int
+	variable string
+	// value is an entry in the line format, used if isList is false
+	value    string
+	patterns []string
+	isList   bool
+	// options are defined in parentheses after the variable name, like `APP_IPA(one)`
+	options []string
+}
+
+func newRules(entries []rawRule, logger log.Logger) ([]Rule, error) {
+	var rules []Rule
+
+	for _, entry := range entries {
+		var err error
+		if entry.variable, entry.options, err = splitVariableOptions(entry.variable); err != nil {
+			return nil, newExportMapError(entry.line, "%s", err)
+		}
+
+		if entry.variable == "" {
+			return nil, newExportMapError(entry.line, "missing variable name")
+		}
+
+		rule, err := newRule(entry)
+		if errors.Is(err, errNoPattern) {
+			logger.Warnf("Export map line %d: no pattern is defined for %s, the entry is skipped", entry.line, entry.variable)
+			continue
+		}
+		if err != nil {
+			return nil, newExportMapError(entry.line, "%s", err)
+		}
+
+		for i, defined := range rules {
+			if defined.Variable == rule.Variable {
+				logger.Warnf("Export map line %d: %s is already defined in line %d, the later entry is used", entry.line, rule.Variable, defined.Line)
+				rules = append(rules[:i], rules[i+1:]...)
+				break
+			}
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+func newRule(entry rawRule) (Rule, error) {
+	rule := Rule{Variable: entry.variable, Line: entry.line}
+
+	for _, option := range entry.options {
+		if err := rule.applyOption(option); err != nil {
+			return Rule{}, err
+		}
+	}
+
+	patterns := entry.patterns
+	if !entry.isList {
+		value, rest, err := splitUnquoted(entry.value, sourceSeparator)
+		if err != nil {
+			return Rule{}, err
+		}
+		if len(rest) > 1 {
+			return Rule{}, fmt.Errorf("%s is defined multiple times", sourceSeparator)
+		}
+
+		if len(rest) == 1 {
+			selectors, err := selector.ParseStructured(value)
+			if err != nil {
+				return Rule{}, err
+			}
+			if len(selectors) != 1 {
+				return Rule{}, fmt.Errorf("exactly one source selector should be defined before %s", sourceSeparator)
+			}
+
+			rule.Source = selectors[0]
+			value = rest[0]
+		}
+
+		value, rest, err = splitUnquoted(value, destinationSeparator)
+		if err != nil {
+			return Rule{}, err
+		}
+		if len(rest) > 1 {
+			return Rule{}, fmt.Errorf("%s is defined multiple times", destinationSeparator)
+		}
+
+		if len(rest) == 1 {
+			if rule.Destination, err = unquote(strings.TrimSpace(rest[0])); err != nil {
+				return Rule{}, err
+			}
+			if rule.Destination == "" {
+				return Rule{}, fmt.Errorf("missing destination after %s", destinationSeparator)
+			}
+		}
+
+		if patterns, err = splitPatterns(value); err != nil {
+			return Rule{}, err
+		}
+	}
+
+	for _, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return Rule{}, fmt.Errorf("invalid pattern (%s): %s", pattern, err)
+		}
+		rule.Patterns = append(rule.Patterns, re)
+	}
+
+	if len(rule.Patterns) == 0 {
+		return Rule{}, fmt.Errorf("%w for %s", errNoPattern, rule.Variable)
+	}
+
+	return rule, nil
+}
+
+// splitVariableOptions splits the `VARIABLE(option1, option2)` form to the variable name and the options
+func splitVariableOptions(key string) (string, []string, error) {
+	i := strings.Index(key, "(")
+	if i < 0 {
+		return key, nil, nil
+	}
+	if !strings.HasSuffix(key, ")") {
+		return "", nil, fmt.Errorf("missing closing parenthesis after the options of %s", key)
+	}
+
+	var options []string
+	for _, option := range strings.Split(key[i+1:len(key)-1], ",") {
+		if option = strings.TrimSpace(option); option != "" {
+			options = append(options, option)
+		}
+	}
+
+	return strings.TrimSpace(key[:i]), options, nil
+}
+
+func (r *Rule) applyOption(option string) error {
+	name, value := option, ""
+	if i := strings.Index(option, "="); i >= 0 {
+		name, value = strings.TrimSpace(option[:i]), strings.TrimSpace(option[i+1:])
+	}
+
+	switch name {
+	case CardinalityExactlyOne, CardinalityAtLeastOne, CardinalityFirst, CardinalityAtMost:
+		if r.Cardinality.Kind != CardinalityAny {
+			return fmt.Errorf("multiple cardinality options are defined: %s, %s", r.Cardinality, option)
+		}
+
+		r.Cardinality.Kind = name
+		if name == CardinalityAtMost {
+			max, err := strconv.Atoi(value)
+			if err != nil || max < 1 {
+				return fmt.Errorf("%s option requires a positive number, like %s=2: %s", name, name, option)
+			}
+			r.Cardinality.Max = max
+		} else if value != "" {
+			return fmt.Errorf("%s option does not have a value: %s", name, option)
+		}
+	case "encoding":
+		if !isEncoding(value) {
+			return fmt.Errorf("unknown encoding: %s, supported encodings: %s, %s, %s, %s", value, EncodingPipe, EncodingNewline, EncodingJSON, EncodingFile)
+		}
+		r.Encoding = value
+	default:
+		return fmt.Errorf("unknown option: %s", option)
+	}
+
+	return nil
+}
+
+// splitUnquoted splits the value at the first unquoted separator, and returns the part before it
+// and the rest split at the further unquoted separators (the rest is empty if there is no separator).
+// A quote only starts a quoted part at the beginning of the value or after a separator (`,`, `::` or `->`), so
+// patterns like `.*it's.*` are kept as they are.
+func splitUnquoted(value, separator string) (string, []string, error) {
+	var parts []string
+	start, valueStart := 0, 0
+	var quote rune
+
+	for i := 0; i < len(value); i++ {
+		c := rune(value[i])
+		switch {
+		case quote == '"' && c == '\\' && i+1 < len(value) && value[i+1] == '"':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+		case (c == '"' || c == '\'') && strings.TrimSpace(value[valueStart:i]) == "":
+			quote = c
+		case strings.HasPrefix(value[i:], separator):
+			parts = append(parts, value[start:i])
+			start = i + len(separator)
+			valueStart = start
+			i += len(separator) - 1
+		default:
+			for _, sep := range []string{",", sourceSeparator, destinationSeparator} {
+				if strings.HasPrefix(value[i:], sep) {
+					valueStart = i + len(sep)
+					i += len(sep) - 1
+					break
+				}
+			}
+		}
+	}
+	if quote != 0 {
+		return "", nil, fmt.Errorf("unterminated %c quote: %s", quote, value)
+	}
+	parts = append(parts, value[start:])
+
+	return parts[0], parts[1:], nil
+}
+
+// splitPatterns splits the comma separated patterns, and removes the quotes of the quoted patterns
+func splitPatterns(value string) ([]string, error) {
+	first, rest, err := splitUnquoted(value, ",")
+	if err != nil {
+		return nil, err
+	}
+
+	var patterns []string
+	for _, part := range append([]string{first}, rest...) {
+		pattern, err := unquote(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	return patterns, nil
+}
+
+func unquote(value string) (string, error) {
+	if len(value) < 2 {
+		return value, nil
+	}
+
+	switch quote := value[0]; {
+	case quote == '\'' && value[len(value)-1] == '\'':
+		return value[1 : len(value)-1], nil
+	case quote == '"' && value[len(value)-1] == '"':
+		return strings.ReplaceAll(value[1:len(value)-1], `\"`, `"`), nil
+	case quote == '\'' || quote == '"':
+		return "", fmt.Errorf("a quoted pattern should not be followed by other characters: %s", value)
+	default:
+		return value, nil
+	}
+}
@@ -0,0 +1,294 @@
+package export
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type warningLogger struct {
+	log.Logger
+	warnings []string
+}
+
+func (l *warningLogger) Warnf(format string, v ...interface{}) {
+	l.warnings = append(l.warnings, fmt.Sprintf(format, v...))
+}
+
+func rulePatterns(rules []Rule) map[string][]string {
+	res := make(map[string][]string)
+	for _, rule := range rules {
+		for _, pattern := range rule.Patterns {
+			res[rule.Variable] = append(res[rule.Variable], pattern.String())
+		}
+	}
+
+	return res
+}
+
+func TestParseExportMap(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		input          string
+		expectedOutput map[string][]string
+	}{
+		{
+			desc: "when input is given, parses the input string",
+			input: `
+DOWNLOADED_APKS: .*\.apk
+DOWNLOADED_TEST_RESULTS: .*\.result
+`,
+			expectedOutput: map[string][]string{
+				"DOWNLOADED_APKS":         {".*\\.apk"},
+				"DOWNLOADED_TEST_RESULTS": {".*\\.result"},
+			},
+		},
+		{
+			desc: "when input is complex, parses the input string",
+			input: `
+DOWNLOADED_APKS: .*_prod.apk
+DOWNLOADED_TEST_RESULTS: results/.*
+`,
+			expectedOutput: map[string][]string{
+				"DOWNLOADED_APKS":         {".*_prod.apk"},
+				"DOWNLOADED_TEST_RESULTS": {"results/.*"},
+			},
+		},
+		{
+			desc: "when multiple expressions presents in the expression field",
+			input: `
+ARTIFACTS: .*\.apk,.*ipa
+TEXTS: .*\.txt, .*docx
+`,
+			expectedOutput: map[string][]string{
+				"ARTIFACTS": {".*\\.apk", ".*ipa"},
+				"TEXTS":     {".*\\.txt", ".*docx"},
+			},
+		},
+		{
+			desc: "when a pattern contains colons, it keeps them",
+			input: `
+WINDOWS_PATHS: C:\\builds\\.*
+URLS: https://example\.com/.*
+`,
+			expectedOutput: map[string][]string{
+				"WINDOWS_PATHS": {"C:\\\\builds\\\\.*"},
+				"URLS":          {"https://example\\.com/.*"},
+			},
+		},
+		{
+			desc: "when patterns are quoted, they can contain commas and separators",
+			input: `
+REPORTS: "report_[0-9]{1,3}\.xml", 'a::b', "say \"hi\""
+`,
+			expectedOutput: map[string][]string{
+				"REPORTS": {"report_[0-9]{1,3}\\.xml", "a::b", `say "hi"`},
+			},
+		},
+		{
+			desc:  "when the input is a JSON object",
+			input: `{"APKS": ".*\\.apk", "REPORTS": ["report_[0-9]{1,3}\\.xml", ".*\\.html"]}`,
+			expectedOutput: map[string][]string{
+				"APKS":    {".*\\.apk"},
+				"REPORTS": {"report_[0-9]{1,3}\\.xml", ".*\\.html"},
+			},
+		},
+		{
+			desc: "when the input is a YAML mapping",
+			input: `
+---
+APKS: .*\.apk
+REPORTS:
+  - report_[0-9]{1,3}\.xml
+  - .*\.html
+`,
+			expectedOutput: map[string][]string{
+				"APKS":    {".*\\.apk"},
+				"REPORTS": {"report_[0-9]{1,3}\\.xml", ".*\\.html"},
+			},
+		},
+		{
+			desc: "when the first YAML entry is a list, the marker is not needed",
+			input: `
+REPORTS:
+  - .*\.html
+APKS: .*\.apk
+`,
+			expectedOutput: map[string][]string{
+				"REPORTS": {".*\\.html"},
+				"APKS":    {".*\\.apk"},
+			},
+		},
+		{
+			desc:  "when a line format entry is indented, it is not parsed as YAML",
+			input: "APKS: .*\\.apk\n IPAS: .*\\.ipa\n\t- DSYMS: .*\\.dSYM\\.zip",
+			expectedOutput: map[string][]string{
+				"APKS":    {".*\\.apk"},
+				"IPAS":    {".*\\.ipa"},
+				"- DSYMS": {".*\\.dSYM\\.zip"},
+			},
+		},
+		{
+			desc: "when a quote is inside a pattern, it is kept",
+			input: `
+NOTES: .*it's.*, "quoted, pattern", .*say "hi".*
+`,
+			expectedOutput: map[string][]string{
+				"NOTES": {".*it's.*", "quoted, pattern", `.*say "hi".*`},
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			rules, err := ParseExportMap(tC.input, log.NewLogger())
+
+			assert.NoError(t, err)
+			assert.Equal(t, tC.expectedOutput, rulePatterns(rules))
+		})
+	}
+}
+
+func TestParseExportMap_KeepsOrderAndSource(t *testing.T) {
+	rules, err := ParseExportMap(`
+STAGING_APK: stage=build-staging workflow=android :: .*\.apk
+ALL: .*
+`, log.NewLogger())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(rules))
+	assert.Equal(t, "STAGING_APK", rules[0].Variable)
+	assert.Equal(t, 2, rules[0].Line)
+	assert.Equal(t, "stage=build-staging workflow=android", rules[0].Source.String())
+	assert.Equal(t, "ALL", rules[1].Variable)
+	assert.Nil(t, rules[1].Source)
+}
+
+func TestParseExportMap_Destination(t *testing.T) {
+	rules, err := ParseExportMap(`
+APP_IPA: stage=build :: .*\.ipa -> $BITRISE_SOURCE_DIR/dist/app.ipa
+ARROWS: "a->b" -> 'dist/{filename}'
+`, log.NewLogger())
+
+	assert.NoError(t, err)
+	assert.Equal(t, "$BITRISE_SOURCE_DIR/dist/app.ipa", rules[0].Destination)
+	assert.Equal(t, "stage=build", rules[0].Source.String())
+	assert.Equal(t, "a->b", rules[1].Patterns[0].String())
+	assert.Equal(t, "dist/{filename}", rules[1].Destination)
+}
+
+func TestParseExportMap_Options(t *testing.T) {
+	rules, err := ParseExportMap(`
+APP_IPA(one): .*\.ipa
+APKS( at-most=2, encoding=json ): .*\.apk
+`, log.NewLogger())
+
+	assert.NoError(t, err)
+	assert.Equal(t, "APP_IPA", rules[0].Variable)
+	assert.Equal(t, Cardinality{Kind: CardinalityExactlyOne}, rules[0].Cardinality)
+	assert.Equal(t, "APKS", rules[1].Variable)
+	assert.Equal(t, Cardinality{Kind: CardinalityAtMost, Max: 2}, rules[1].Cardinality)
+	assert.Equal(t, EncodingJSON, rules[1].Encoding)
+}
+
+func TestParseExportMap_Warnings(t *testing.T) {
+	logger := &warningLogger{}
+
+	rules, err := ParseExportMap(`
+EMPTY:
+APKS: .*\.apk
+ALL: .*
+APKS: .*\.aab
+`, logger)
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{"ALL": {".*"}, "APKS": {".*\\.aab"}}, rulePatterns(rules))
+	assert.Equal(t, "ALL", rules[0].Variable)
+	assert.Equal(t, "APKS", rules[1].Variable)
+	assert.Equal(t, []string{
+		"Export map line 2: no pattern is defined for EMPTY, the entry is skipped",
+		"Export map line 5: APKS is already defined in line 3, the later entry is used",
+	}, logger.warnings)
+}
+
+func TestParseExportMap_Errors(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		input         string
+		expectedError string
+	}{
+		{
+			desc:          "when a line has no colon",
+			input:         "APKS: .*\\.apk\nIPAS",
+			expectedError: "invalid export map, line 2: missing ':' separator after the variable name: IPAS",
+		},
+		{
+			desc:          "when the variable name is missing",
+			input:         "APKS: .*\\.apk\n: .*\\.ipa",
+			expectedError: "invalid export map, line 2: missing variable name",
+		},
+		{
+			desc:          "when a pattern is an invalid regex",
+			input:         "APKS: *.apk",
+			expectedError: "invalid export map, line 1: invalid pattern (*.apk): error parsing regexp: missing argument to repetition operator: `*`",
+		},
+		{
+			desc:          "when a quote is not terminated",
+			input:         `APKS: ".*\.apk`,
+			expectedError: `invalid export map, line 1: unterminated " quote: ".*\.apk`,
+		},
+		{
+			desc:          "when the source selector is invalid",
+			input:         `APKS: branch=main :: .*\.apk`,
+			expectedError: "invalid export map, line 1: invalid artifact source selector (branch=main): unknown key: branch",
+		},
+		{
+			desc:          "when the destination is missing",
+			input:         `APKS: .*\.apk ->`,
+			expectedError: "invalid export map, line 1: missing destination after ->",
+		},
+		{
+			desc:          "when an option is unknown",
+			input:         `APKS(all): .*\.apk`,
+			expectedError: "invalid export map, line 1: unknown option: all",
+		},
+		{
+			desc:          "when an encoding is unknown",
+			input:         `APKS(encoding=csv): .*\.apk`,
+			expectedError: "invalid export map, line 1: unknown encoding: csv, supported encodings: pipe, newline, json, file",
+		},
+		{
+			desc:          "when multiple cardinality options are defined",
+			input:         `APKS(one, first): .*\.apk`,
+			expectedError: "invalid export map, line 1: multiple cardinality options are defined: one, first",
+		},
+		{
+			desc:          "when the at most option has no number",
+			input:         `APKS(at-most): .*\.apk`,
+			expectedError: "invalid export map, line 1: at-most option requires a positive number, like at-most=2: at-most",
+		},
+		{
+			desc:          "when the options are not closed",
+			input:         `APKS(one: .*\.apk`,
+			expectedError: "invalid export map, line 1: missing closing parenthesis after the options of APKS(one",
+		},
+		{
+			desc:          "when a JSON value is not a string",
+			input:         "{\n\"APKS\": 1\n}",
+			expectedError: "invalid export map, line 2: the value of APKS should be a string or an array of strings",
+		},
+		{
+			desc:          "when a YAML pattern is invalid",
+			input:         "APKS:\n  - .*\\.apk\n  - (",
+			expectedError: "invalid export map, line 1: invalid pattern ((): error parsing regexp: missing closing ): `(`",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			_, err := ParseExportMap(tC.input, log.NewLogger())
+
+			assert.EqualError(t, err, tC.expectedError)
+		})
+	}
+}
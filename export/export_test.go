@@ -6,69 +6,24 @@ import (
 
 	mockenv "github.com/bitrise-io/go-utils/env/mocks"
 	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/model"
 	"github.com/stretchr/testify/assert"
 )
 
-func TestProcessRawExportMap(t *testing.T) {
-	testCases := []struct {
-		desc           string
-		input          string
-		expectedOutput map[string]string
-	}{
-		{
-			desc: "when input is given, parses the input string",
-			input: `
-DOWNLOADED_APKS: .*\.apk
-DOWNLOADED_TEST_RESULTS: .*\.result
-`,
-			expectedOutput: map[string]string{
-				"DOWNLOADED_APKS":         ".*\\.apk",
-				"DOWNLOADED_TEST_RESULTS": ".*\\.result",
-			},
-		},
-		{
-			desc: "when input is complex, parses the input string",
-			input: `
-DOWNLOADED_APKS: .*_prod.apk
-DOWNLOADED_TEST_RESULTS: results/.*
-`,
-			expectedOutput: map[string]string{
-				"DOWNLOADED_APKS":         ".*_prod.apk",
-				"DOWNLOADED_TEST_RESULTS": "results/.*",
-			},
-		},
-		{
-			desc: "when multiple expressions presents in the expression field",
-			input: `
-ARTIFACTS: *.apk,*ipa
-TEXTS: *.txt,*docx
-`,
-			expectedOutput: map[string]string{
-				"ARTIFACTS": "*.apk,*ipa",
-				"TEXTS":     "*.txt,*docx",
-			},
-		},
-		{
-			desc: "when the input has a messed up line",
-			input: `
-DOWNLOADED_APKS: *.apk 
-: *.ipa
-DOWNLOADED_TEST_RESULTS: *.result
-EMPTY: 
-`,
-			expectedOutput: map[string]string{
-				"DOWNLOADED_APKS":         "*.apk",
-				"DOWNLOADED_TEST_RESULTS": "*.result",
-			},
-		},
+func pulledArtifacts(paths ...string) []model.PulledArtifact {
+	var artifacts []model.PulledArtifact
+	for _, path := range paths {
+		artifacts = append(artifacts, model.PulledArtifact{Path: path})
 	}
-	for _, tC := range testCases {
-		t.Run(tC.desc, func(t *testing.T) {
-			result := ProcessRawExportMap(tC.input)
 
-			assert.Equal(t, tC.expectedOutput, result)
-		})
-	}
+	return artifacts
+}
+
+func exportRules(t *testing.T, rawExportMap string) []Rule {
+	rules, err := ParseExportMap(rawExportMap, log.NewLogger())
+	assert.NoError(t, err)
+
+	return rules
 }
 
 func TestSimpleOutputExport_NoError(t *testing.T) {
@@ -77,7 +32,7 @@ func TestSimpleOutputExport_NoError(t *testing.T) {
 	envRepository.On("Set", "BITRISE_ARTIFACT_PATHS", "a/b.txt").Return(nil)
 
 	exporter := OutputExporter{
-		ExportValues:  "a/b.txt",
+		Artifacts:     pulledArtifacts("a/b.txt"),
 		Logger:        log.NewLogger(),
 		EnvRepository: envRepository,
 	}
@@ -94,7 +49,7 @@ func TestSimpleOutputExport_Error(t *testing.T) {
 	envRepository.On("Set", "BITRISE_ARTIFACT_PATHS", "a/b.txt").Return(errors.New("some kind of error"))
 
 	exporter := OutputExporter{
-		ExportValues:  "a/b.txt",
+		Artifacts:     pulledArtifacts("a/b.txt"),
 		Logger:        log.NewLogger(),
 		EnvRepository: envRepository,
 	}
@@ -112,13 +67,13 @@ func TestPatternBasedOutputExport_SingleFile_NoError(t *testing.T) {
 	envRepository.On("Set", "APK_FILES", "b.apk").Return(nil)
 
 	exporter := OutputExporter{
-		ExportValues:  "a.txt|b.apk",
+		Artifacts:     pulledArtifacts("a.txt", "b.apk"),
 		Logger:        log.NewLogger(),
 		EnvRepository: envRepository,
-		ExportPattern: map[string]string{
-			"TXT_FILES": ".*\\.txt",
-			"APK_FILES": ".*\\.apk",
-		},
+		Rules: exportRules(t, `
+TXT_FILES: .*\.txt
+APK_FILES: .*\.apk
+`),
 	}
 
 	err := exporter.Export()
@@ -135,14 +90,14 @@ func TestPatternBasedOutputExport_ComplexPattern_NoError(t *testing.T) {
 	envRepository.On("Set", "PROD_FILES", "/release/prod/android_prod.apk|/release/prod/android_prod2.apk|/release/prod/ios_prod.ipa|/release/prod/ios_test.ipa").Return(nil)
 
 	exporter := OutputExporter{
-		ExportValues:  "/release/prod/android_prod.apk|/release/prod/android_prod2.apk|/release/prod/ios_prod.ipa|/release/prod/ios_test.ipa",
+		Artifacts:     pulledArtifacts("/release/prod/android_prod.apk", "/release/prod/android_prod2.apk", "/release/prod/ios_prod.ipa", "/release/prod/ios_test.ipa"),
 		Logger:        log.NewLogger(),
 		EnvRepository: envRepository,
-		ExportPattern: map[string]string{
-			"PROD_FILES": ".*/prod/.*",
-			"IPA_FILES":  ".*\\.ipa",
-			"APK_FILES":  ".*\\.apk",
-		},
+		Rules: exportRules(t, `
+PROD_FILES: .*/prod/.*
+IPA_FILES: .*\.ipa
+APK_FILES: .*\.apk
+`),
 	}
 
 	err := exporter.Export()
@@ -158,13 +113,13 @@ func TestPatternBasedOutputExport_MultipleFiles_NoError(t *testing.T) {
 	envRepository.On("Set", "APK_FILES", "b.apk").Return(nil)
 
 	exporter := OutputExporter{
-		ExportValues:  "a.txt|b.txt|b.apk|x.ipa",
+		Artifacts:     pulledArtifacts("a.txt", "b.txt", "b.apk", "x.ipa"),
 		Logger:        log.NewLogger(),
 		EnvRepository: envRepository,
-		ExportPattern: map[string]string{
-			"TXT_FILES": ".*\\.txt",
-			"APK_FILES": ".*\\.apk",
-		},
+		Rules: exportRules(t, `
+TXT_FILES: .*\.txt
+APK_FILES: .*\.apk
+`),
 	}
 
 	err := exporter.Export()
@@ -181,14 +136,42 @@ func TestPatternBasedOutputExport_MultipleFiles_MultipleExpressions_NoError(t *t
 	envRepository.On("Set", "ALL", "a.txt|b.txt|b.apk|x.ipa|d.docx").Return(nil)
 
 	exporter := OutputExporter{
-		ExportValues:  "a.txt|b.txt|b.apk|x.ipa|d.docx",
+		Artifacts:     pulledArtifacts("a.txt", "b.txt", "b.apk", "x.ipa", "d.docx"),
 		Logger:        log.NewLogger(),
 		EnvRepository: envRepository,
-		ExportPattern: map[string]string{
-			"TEXT_FILES": ".*\\.txt,.*\\.docx",
-			"APK_FILES":  ".*\\.apk",
-			"ALL":        ".*",
+		Rules: exportRules(t, `
+TEXT_FILES: .*\.txt,.*\.docx
+APK_FILES: .*\.apk
+ALL: .*
+`),
+	}
+
+	err := exporter.Export()
+	assert.NoError(t, err)
+
+	envRepository.AssertExpectations(t)
+}
+
+func TestPatternBasedOutputExport_SourceSelector_NoError(t *testing.T) {
+	envRepository := new(mockenv.Repository)
+
+	envRepository.On("Set", "STAGING_APK", "/tmp/staging/app.apk").Return(nil)
+	envRepository.On("Set", "PROD_APK", "/tmp/prod/app.apk").Return(nil)
+	envRepository.On("Set", "ALL_APKS", "/tmp/staging/app.apk|/tmp/prod/app.apk").Return(nil)
+
+	exporter := OutputExporter{
+		Artifacts: []model.PulledArtifact{
+			{Path: "/tmp/staging/app.apk", Stage: "build-staging", Workflow: "android"},
+			{Path: "/tmp/prod/app.apk", Stage: "build-prod", Workflow: "android"},
+			{Path: "/tmp/prod/app.ipa", Stage: "build-prod", Workflow: "ios"},
 		},
+		Logger:        log.NewLogger(),
+		EnvRepository: envRepository,
+		Rules: exportRules(t, `
+STAGING_APK: stage=build-staging workflow=android :: .*\.apk
+PROD_APK: stage=build-prod :: .*\.apk
+ALL_APKS: workflow=android :: .*
+`),
 	}
 
 	err := exporter.Export()
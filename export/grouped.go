@@ -0,0 +1,111 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/model"
+)
+
+const (
+	groupedVariablePrefix = "BITRISE_ARTIFACT_PATHS_"
+	stageVariablePrefix   = groupedVariablePrefix + "STAGE_"
+	// stageWorkflowSeparator separates the stage and the workflow name in the per-workflow variable names
+	stageWorkflowSeparator = "__"
+)
+
+type pathGroup struct {
+	variable  string
+	key       string
+	artifacts []model.PulledArtifact
+}
+
+// groupedOutputExport exports the paths of the artifacts pulled from each stage and each stage workflow, like
+// BITRISE_ARTIFACT_PATHS_STAGE_BUILD and BITRISE_ARTIFACT_PATHS_BUILD__ANDROID
+func (oe OutputExporter) groupedOutputExport() error {
+	groups, err := groupArtifacts(oe.Artifacts)
+	if err != nil {
+		return err
+	}
+	if len(groups) == 0 {
+		return nil
+	}
+
+	oe.Logger.Println()
+	oe.Logger.Printf("The following per-stage and per-workflow outputs are exported as environment variables:")
+
+	for _, group := range groups {
+		exportValue, err := oe.encode(group.variable, artifactPaths(group.artifacts), "")
+		if err != nil {
+			return err
+		}
+
+		if err := oe.exportOutputVariable(group.variable, exportValue); err != nil {
+			return err
+		}
+
+		oe.Logger.Printf("$%s = %s", group.variable, exportValue)
+	}
+
+	return nil
+}
+
+// groupArtifacts groups the artifacts by stage and by stage workflow. Artifacts without stage information
+// (pulled from a build outside of the pipeline) are not grouped.
+func groupArtifacts(artifacts []model.PulledArtifact) ([]pathGroup, error) {
+	var groups []pathGroup
+	indexByVariable := make(map[string]int)
+
+	add := func(variable, key string, artifact model.PulledArtifact) error {
+		i, ok := indexByVariable[variable]
+		if !ok {
+			indexByVariable[variable] = len(groups)
+			groups = append(groups, pathGroup{variable: variable, key: key, artifacts: []model.PulledArtifact{artifact}})
+			return nil
+		}
+
+		if groups[i].key != key {
+			return fmt.Errorf("%s and %s result in the same output variable name: %s", groups[i].key, key, variable)
+		}
+		groups[i].artifacts = append(groups[i].artifacts, artifact)
+
+		return nil
+	}
+
+	for _, artifact := range artifacts {
+		if artifact.Stage == "" || artifact.Workflow == "" {
+			continue
+		}
+
+		if err := add(stageVariableName(artifact.Stage), artifact.Stage, artifact); err != nil {
+			return nil, err
+		}
+		if err := add(workflowVariableName(artifact.Stage, artifact.Workflow), artifact.Stage+"."+artifact.Workflow, artifact); err != nil {
+			return nil, err
+		}
+	}
+
+	return groups, nil
+}
+
+func stageVariableName(stage string) string {
+	return stageVariablePrefix + sanitizeVariableName(stage)
+}
+
+func workflowVariableName(stage, workflow string) string {
+	return groupedVariablePrefix + sanitizeVariableName(stage) + stageWorkflowSeparator + sanitizeVariableName(workflow)
+}
+
+// sanitizeVariableName upper-cases the name and replaces the characters not allowed in environment variable names with underscores
+func sanitizeVariableName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
@@ -0,0 +1,61 @@
+package export
+
+import (
+	"testing"
+
+	mockenv "github.com/bitrise-io/go-utils/env/mocks"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupedOutputExport(t *testing.T) {
+	envRepository := new(mockenv.Repository)
+	envRepository.On("Set", "BITRISE_ARTIFACT_PATHS", "app.apk|app.ipa|app-latest.apk").Return(nil)
+	envRepository.On("Set", "BITRISE_ARTIFACT_PATHS_STAGE_BUILD", "app.apk|app.ipa").Return(nil)
+	envRepository.On("Set", "BITRISE_ARTIFACT_PATHS_BUILD__ANDROID", "app.apk").Return(nil)
+	envRepository.On("Set", "BITRISE_ARTIFACT_PATHS_BUILD__IOS_APP", "app.ipa").Return(nil)
+
+	exporter := OutputExporter{
+		Artifacts: []model.PulledArtifact{
+			{Path: "app.apk", Stage: "build", Workflow: "android"},
+			{Path: "app.ipa", Stage: "build", Workflow: "ios-app"},
+			{Path: "app-latest.apk", Workflow: "android"},
+		},
+		GroupedPaths:  true,
+		Logger:        log.NewLogger(),
+		EnvRepository: envRepository,
+	}
+
+	err := exporter.Export()
+	assert.NoError(t, err)
+
+	envRepository.AssertExpectations(t)
+}
+
+func TestGroupArtifacts_NameCollision(t *testing.T) {
+	artifacts := []model.PulledArtifact{
+		{Path: "a.apk", Stage: "build", Workflow: "ios-app"},
+		{Path: "b.apk", Stage: "build", Workflow: "ios_app"},
+	}
+
+	_, err := groupArtifacts(artifacts)
+	assert.EqualError(t, err, "build.ios-app and build.ios_app result in the same output variable name: BITRISE_ARTIFACT_PATHS_BUILD__IOS_APP")
+}
+
+func TestSanitizeVariableName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "build", want: "BUILD"},
+		{name: "ios-app", want: "IOS_APP"},
+		{name: "Deploy to Store 2", want: "DEPLOY_TO_STORE_2"},
+		{name: "ütf", want: "_TF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, sanitizeVariableName(tt.name))
+		})
+	}
+}
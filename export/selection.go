@@ -0,0 +1,58 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/model"
+)
+
+type selectionError struct {
+	input string
+	line  int
+	msg   string
+}
+
+func (e selectionError) Error() string {
+	return fmt.Sprintf("invalid %s, line %d: %s", e.input, e.line, e.msg)
+}
+
+// ParseSelection parses one `[source selector ::] pattern1, pattern2` entry per line, with the same syntax as the
+// values of the line format export map. The input name is used in the error messages.
+// Selection rules have no variable name, an artifact is selected if any of the rules match it (see SelectArtifacts).
+func ParseSelection(input, raw string) ([]Rule, error) {
+	var rules []Rule
+	for i, line := range strings.Split(raw, "\n") {
+		value := strings.TrimSpace(line)
+		if value == "" {
+			continue
+		}
+
+		rule, err := newRule(rawRule{line: i + 1, variable: "the entry", value: value})
+		if err != nil {
+			return nil, selectionError{input: input, line: i + 1, msg: err.Error()}
+		}
+		if rule.Destination != "" {
+			return nil, selectionError{input: input, line: i + 1, msg: fmt.Sprintf("destination (%s) is not supported", destinationSeparator)}
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// SelectArtifacts returns the artifacts matching any of the selection rules, in the order of the artifacts
+func SelectArtifacts(selection []Rule, artifacts []model.PulledArtifact) []model.PulledArtifact {
+	var selected []model.PulledArtifact
+	for _, artifact := range artifacts {
+		for _, rule := range selection {
+			if rule.match(artifact) {
+				selected = append(selected, artifact)
+				break
+			}
+		}
+	}
+
+	return selected
+}
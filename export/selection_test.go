@@ -0,0 +1,22 @@
+package export
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSelection(t *testing.T) {
+	rules, err := ParseSelection("deploy_artifacts", ".*\\.apk, .*\\.aab\n\nstage=test :: .*\\.xml\n")
+	assert.NoError(t, err)
+	assert.Len(t, rules, 2)
+	assert.Nil(t, rules[0].Source)
+	assert.Len(t, rules[0].Patterns, 2)
+	assert.Equal(t, "stage=test", rules[1].Source.String())
+
+	_, err = ParseSelection("deploy_artifacts", ".*\\.apk\n.*\\.ipa -> $BITRISE_DEPLOY_DIR/app.ipa")
+	assert.EqualError(t, err, "invalid deploy_artifacts, line 2: destination (->) is not supported")
+
+	_, err = ParseSelection("deploy_artifacts", "stage=build ::")
+	assert.EqualError(t, err, "invalid deploy_artifacts, line 1: no pattern is defined for the entry")
+}
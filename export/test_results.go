@@ -0,0 +1,191 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/model"
+)
+
+const (
+	testInfoFileName = "test-info.json"
+	// xcresultZipSuffix is the suffix of the zipped xcresult bundles, they are extracted into the test run directory
+	xcresultZipSuffix = ".xcresult.zip"
+)
+
+// TestResultExporter places the selected pulled test results into the test result directory, one test run directory
+// per source stage and workflow, in the Bitrise test results format
+type TestResultExporter struct {
+	Selection     []Rule
+	Artifacts     []model.PulledArtifact
+	TestResultDir string
+	Logger        log.Logger
+}
+
+type testRun struct {
+	dir       string
+	name      string
+	artifacts []model.PulledArtifact
+}
+
+type testInfo struct {
+	TestName string `json:"test-name"`
+}
+
+// Export places the selected test results and returns the created test run directories
+func (te TestResultExporter) Export() ([]string, error) {
+	runs := te.testRuns()
+	if len(runs) == 0 {
+		te.Logger.Warnf("None of the pulled artifacts is selected as a test result")
+		return nil, nil
+	}
+
+	var dirs []string
+	for _, run := range runs {
+		runDir := filepath.Join(te.TestResultDir, run.dir)
+		if err := te.exportTestRun(runDir, run); err != nil {
+			return nil, fmt.Errorf("failed to export the test results of %s: %w", run.name, err)
+		}
+		te.Logger.Printf("%d test result(s) of %s are placed to %s", len(run.artifacts), run.name, runDir)
+
+		dirs = append(dirs, runDir)
+	}
+
+	return dirs, nil
+}
+
+// testRuns groups the selected artifacts by source stage and workflow, in the order of the artifacts
+func (te TestResultExporter) testRuns() []testRun {
+	var runs []testRun
+	indexByDir := make(map[string]int)
+
+	for _, artifact := range SelectArtifacts(te.Selection, te.Artifacts) {
+		dir, name := testRunDirAndName(artifact)
+		i, ok := indexByDir[dir]
+		if !ok {
+			i = len(runs)
+			indexByDir[dir] = i
+			runs = append(runs, testRun{dir: dir, name: name})
+		}
+		runs[i].artifacts = append(runs[i].artifacts, artifact)
+	}
+
+	return runs
+}
+
+func testRunDirAndName(artifact model.PulledArtifact) (string, string) {
+	switch {
+	case artifact.Stage != "" && artifact.Workflow != "":
+		return safeFileName(artifact.Stage + "-" + artifact.Workflow), artifact.Stage + " / " + artifact.Workflow
+	case artifact.Workflow != "":
+		return safeFileName(artifact.Workflow), artifact.Workflow
+	default:
+		return "artifact-pull", "Pulled test results"
+	}
+}
+
+func (te TestResultExporter) exportTestRun(runDir string, run testRun) error {
+	if err := os.MkdirAll(runDir, 0o755); err != nil {
+		return err
+	}
+
+	used := map[string]bool{testInfoFileName: true}
+	taken := func(name string) bool {
+		if used[name] {
+			return true
+		}
+		_, err := os.Lstat(filepath.Join(runDir, name))
+		return err == nil
+	}
+
+	for _, artifact := range run.artifacts {
+		if strings.HasSuffix(artifact.Path, xcresultZipSuffix) {
+			if err := unzip(artifact.Path, runDir); err != nil {
+				return fmt.Errorf("failed to extract %s: %w", artifact.Path, err)
+			}
+			continue
+		}
+
+		name := deployFileName(artifact, taken)
+		used[name] = true
+
+		if err := placeFile(artifact.Path, filepath.Join(runDir, name), DestinationModeHardlink); err != nil {
+			return fmt.Errorf("failed to place %s: %w", artifact.Path, err)
+		}
+	}
+
+	content, err := json.Marshal(testInfo{TestName: run.name})
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(runDir, testInfoFileName), content, 0o644)
+}
+
+// unzip extracts the archive into the directory, entries pointing outside of the directory are rejected
+func unzip(archive, dir string) error {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	for _, f := range r.File {
+		pth := filepath.Join(dir, f.Name)
+		if pth != dir && !strings.HasPrefix(pth, filepath.Clean(dir)+string(filepath.Separator)) {
+			return fmt.Errorf("invalid file path in the archive: %s", f.Name)
+		}
+
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(pth, 0o755); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := extractFile(f, pth); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func extractFile(f *zip.File, pth string) (err error) {
+	if err := os.MkdirAll(filepath.Dir(pth), 0o755); err != nil {
+		return err
+	}
+
+	in, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := in.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	out, err := os.OpenFile(pth, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode().Perm()|0o600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	_, err = io.Copy(out, in)
+
+	return err
+}
@@ -0,0 +1,85 @@
+package export
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeZip(t *testing.T, pth string, files map[string]string) {
+	f, err := os.Create(pth)
+	assert.NoError(t, err)
+
+	w := zip.NewWriter(f)
+	for name, content := range files {
+		fw, err := w.Create(name)
+		assert.NoError(t, err)
+		_, err = fw.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, w.Close())
+	assert.NoError(t, f.Close())
+}
+
+func TestTestResultExporter_Export(t *testing.T) {
+	sourceDir := t.TempDir()
+	testResultDir := t.TempDir()
+
+	writeFile := func(name, content string) string {
+		pth := filepath.Join(sourceDir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(pth), 0o755))
+		assert.NoError(t, ioutil.WriteFile(pth, []byte(content), 0o644))
+		return pth
+	}
+	xcresultPath := filepath.Join(sourceDir, "Tests.xcresult.zip")
+	writeZip(t, xcresultPath, map[string]string{"Tests.xcresult/Info.plist": "plist"})
+
+	artifacts := []model.PulledArtifact{
+		{Path: writeFile("shard1/junit.xml", "shard 1"), Stage: "test", Workflow: "unit-test", BuildSlug: "build-1"},
+		{Path: writeFile("shard2/junit.xml", "shard 2"), Stage: "test", Workflow: "unit-test", BuildSlug: "build-2"},
+		{Path: xcresultPath, Stage: "test", Workflow: "ui-test", BuildSlug: "build-3"},
+		{Path: writeFile("app.apk", "apk"), Stage: "build", Workflow: "android", BuildSlug: "build-4"},
+	}
+
+	exporter := TestResultExporter{
+		Selection:     exportSelection(t, ".*\\.xml\n.*\\.xcresult\\.zip"),
+		Artifacts:     artifacts,
+		TestResultDir: testResultDir,
+		Logger:        log.NewLogger(),
+	}
+
+	dirs, err := exporter.Export()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(testResultDir, "test-unit-test"), filepath.Join(testResultDir, "test-ui-test")}, dirs)
+
+	expectedFiles := map[string]string{
+		"test-unit-test/junit.xml":                "shard 1",
+		"test-unit-test/test_unit-test_junit.xml": "shard 2",
+		"test-unit-test/test-info.json":           `{"test-name":"test / unit-test"}`,
+		"test-ui-test/Tests.xcresult/Info.plist":  "plist",
+		"test-ui-test/test-info.json":             `{"test-name":"test / ui-test"}`,
+	}
+	for name, expected := range expectedFiles {
+		content, err := ioutil.ReadFile(filepath.Join(testResultDir, name))
+		assert.NoError(t, err, name)
+		assert.Equal(t, expected, string(content), name)
+	}
+
+	_, err = os.Stat(filepath.Join(testResultDir, "build-android"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestUnzip_RejectsPathsOutsideOfTheDirectory(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "evil.xcresult.zip")
+	writeZip(t, archive, map[string]string{"../evil.txt": "evil"})
+
+	err := unzip(archive, filepath.Join(dir, "out"))
+	assert.EqualError(t, err, "invalid file path in the archive: ../evil.txt")
+}
@@ -0,0 +1,34 @@
+// Command fake-bitrise-api serves a fixture directory with the fake Bitrise API, to run the step locally without
+// access to api.bitrise.io:
+//
+//	go run ./fakeapi/cmd/fake-bitrise-api -fixtures ./fakeapi/testdata/fixtures -addr localhost:8080
+//
+// and run the step with bitrise_api_base_url set to http://localhost:8080 (the example fixtures serve the builds of
+// the example-app app).
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/fakeapi"
+)
+
+func main() {
+	fixtures := flag.String("fixtures", "", "fixture directory with the <app slug>/<build slug>/<artifact file> layout")
+	addr := flag.String("addr", "localhost:8080", "listen address")
+	token := flag.String("token", "", "expected access token, any token is accepted if empty")
+	pageSize := flag.Int("page-size", 0, "artifact list page size")
+	flag.Parse()
+
+	spec, err := fakeapi.LoadSpec(*fixtures)
+	if err != nil {
+		log.Fatalf("Failed to load fixtures: %s", err)
+	}
+	spec.Token = *token
+	spec.PageSize = *pageSize
+
+	log.Printf("Serving the fake Bitrise API on http://%s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, fakeapi.NewServer(spec)))
+}
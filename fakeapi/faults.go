@@ -0,0 +1,78 @@
+package fakeapi
+
+import (
+	"strings"
+	"time"
+)
+
+// Endpoint identifies the endpoints of the fake server in fault rules and request counters
+type Endpoint string
+
+const (
+	EndpointAny           Endpoint = ""
+	EndpointListArtifacts Endpoint = "list_artifacts"
+	EndpointShowArtifact  Endpoint = "show_artifact"
+	EndpointListBuilds    Endpoint = "list_builds"
+	EndpointDownload      Endpoint = "download"
+)
+
+// Fault is injected into the responses of the matching requests
+type Fault struct {
+	Endpoint Endpoint
+	// PathContains limits the fault to the requests whose path contains it, like an artifact slug
+	PathContains string
+	// Times limits the number of faulty responses, 0 means every matching request is faulty
+	Times int
+
+	// Latency delays the response
+	Latency time.Duration
+	// Status responds with this status code instead of the regular response, if it is not 0
+	Status int
+	// RetryAfter is sent in the Retry-After header of the responses with a Status
+	RetryAfter time.Duration
+	// Truncate closes the connection after sending half of the response body
+	Truncate bool
+	// ExpiredURLs makes the show artifact endpoint return download URLs which are already expired
+	ExpiredURLs bool
+}
+
+type fault struct {
+	Fault
+	applied int
+}
+
+func (f *fault) matches(endpoint Endpoint, path string) bool {
+	if f.Endpoint != EndpointAny && f.Endpoint != endpoint {
+		return false
+	}
+	if f.PathContains != "" && !strings.Contains(path, f.PathContains) {
+		return false
+	}
+
+	return f.Times == 0 || f.applied < f.Times
+}
+
+// TooManyRequests returns a fault responding 429 to the first `times` requests of the endpoint
+func TooManyRequests(endpoint Endpoint, times int) Fault {
+	return Fault{Endpoint: endpoint, Times: times, Status: 429, RetryAfter: time.Second}
+}
+
+// ServerError returns a fault responding with the 5xx status to the first `times` requests of the endpoint
+func ServerError(endpoint Endpoint, status, times int) Fault {
+	return Fault{Endpoint: endpoint, Times: times, Status: status}
+}
+
+// Latency returns a fault delaying every response of the endpoint
+func Latency(endpoint Endpoint, latency time.Duration) Fault {
+	return Fault{Endpoint: endpoint, Latency: latency}
+}
+
+// TruncatedDownload returns a fault truncating the first `times` downloads of the artifact
+func TruncatedDownload(artifactSlug string, times int) Fault {
+	return Fault{Endpoint: EndpointDownload, PathContains: artifactSlug, Times: times, Truncate: true}
+}
+
+// ExpiredURLs returns a fault making the first `times` show artifact responses contain expired download URLs
+func ExpiredURLs(times int) Fault {
+	return Fault{Endpoint: EndpointShowArtifact, Times: times, ExpiredURLs: true}
+}
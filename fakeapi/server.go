@@ -0,0 +1,360 @@
+package fakeapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/api"
+)
+
+const (
+	defaultPageSize = 50
+	// downloadURLTTL is the validity of the download URLs returned by the show artifact endpoint
+	downloadURLTTL = 10 * time.Minute
+	signingKey     = "fake-bitrise-api"
+)
+
+// Server is a fake Bitrise API serving the artifact list, artifact show, build list and artifact download endpoints
+// from a Spec. It can be used as an http.Handler, or started on a local port with Start.
+type Server struct {
+	spec Spec
+
+	mu       sync.Mutex
+	faults   []*fault
+	requests map[Endpoint]int
+
+	testServer *httptest.Server
+}
+
+func NewServer(spec Spec) *Server {
+	if spec.PageSize <= 0 {
+		spec.PageSize = defaultPageSize
+	}
+
+	return &Server{
+		spec:     spec,
+		requests: map[Endpoint]int{},
+	}
+}
+
+// Start starts the server on a local port and returns its base URL
+func (s *Server) Start() string {
+	s.testServer = httptest.NewServer(s)
+
+	return s.testServer.URL
+}
+
+func (s *Server) Close() {
+	if s.testServer != nil {
+		s.testServer.Close()
+	}
+}
+
+// AddFault injects a fault into the responses of the matching requests, faults are evaluated in the order they are added
+func (s *Server) AddFault(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, &fault{Fault: f})
+}
+
+// Requests returns the number of requests received by the endpoint (EndpointAny counts every request)
+func (s *Server) Requests(endpoint Endpoint) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if endpoint == EndpointAny {
+		total := 0
+		for _, count := range s.requests {
+			total += count
+		}
+		return total
+	}
+
+	return s.requests[endpoint]
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	endpoint, params, ok := route(r.URL.Path)
+	if !ok || r.Method != http.MethodGet {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	faults := s.recordRequest(endpoint, r.URL.Path)
+
+	var truncate, expiredURLs bool
+	for _, f := range faults {
+		if f.Latency > 0 {
+			time.Sleep(f.Latency)
+		}
+		if f.Status != 0 {
+			if f.RetryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(f.RetryAfter.Seconds())))
+			}
+			writeError(w, f.Status, http.StatusText(f.Status))
+			return
+		}
+		truncate = truncate || f.Truncate
+		expiredURLs = expiredURLs || f.ExpiredURLs
+	}
+
+	if endpoint != EndpointDownload && s.spec.Token != "" && r.Header.Get("Authorization") != "Bearer "+s.spec.Token {
+		writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var (
+		status = http.StatusOK
+		body   []byte
+	)
+	switch endpoint {
+	case EndpointListArtifacts:
+		status, body = s.listArtifacts(params[0], params[1], r.URL.Query().Get("next"))
+	case EndpointShowArtifact:
+		status, body = s.showArtifact("http://"+r.Host, params[0], params[1], params[2], expiredURLs)
+	case EndpointListBuilds:
+		status, body = s.listBuilds(params[0], r.URL.Query())
+	case EndpointDownload:
+		status, body = s.download(params[0], params[1], params[2], r.URL.Query())
+	}
+
+	if endpoint == EndpointDownload {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+
+	if truncate && status == http.StatusOK {
+		// the declared length is not sent, so the client gets an unexpected EOF
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(status)
+		_, _ = w.Write(body[:len(body)/2])
+		if hijacker, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hijacker.Hijack(); err == nil {
+				_ = conn.Close()
+			}
+		}
+		return
+	}
+
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+func (s *Server) recordRequest(endpoint Endpoint, path string) []Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests[endpoint]++
+
+	// a request gets at most one status fault, the following ones are kept for the next requests
+	var (
+		faults    []Fault
+		hasStatus bool
+	)
+	for _, f := range s.faults {
+		if !f.matches(endpoint, path) || (f.Status != 0 && hasStatus) {
+			continue
+		}
+
+		f.applied++
+		faults = append(faults, f.Fault)
+		hasStatus = hasStatus || f.Status != 0
+	}
+
+	return faults
+}
+
+// route returns the endpoint and the path parameters of the request path
+func route(path string) (Endpoint, []string, bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	switch {
+	case len(parts) == 6 && parts[0] == "v0.2" && parts[1] == "apps" && parts[3] == "builds" && parts[5] == "artifacts":
+		return EndpointListArtifacts, []string{parts[2], parts[4]}, true
+	case len(parts) == 7 && parts[0] == "v0.2" && parts[1] == "apps" && parts[3] == "builds" && parts[5] == "artifacts":
+		return EndpointShowArtifact, []string{parts[2], parts[4], parts[6]}, true
+	case len(parts) == 4 && parts[0] == "v0.1" && parts[1] == "apps" && parts[3] == "builds":
+		return EndpointListBuilds, []string{parts[2]}, true
+	case len(parts) == 4 && parts[0] == "download":
+		return EndpointDownload, parts[1:], true
+	default:
+		return "", nil, false
+	}
+}
+
+func (s *Server) listArtifacts(appSlug, buildSlug, next string) (int, []byte) {
+	build, ok := s.spec.findBuild(appSlug, buildSlug)
+	if !ok {
+		return errorBody(http.StatusNotFound, "build not found")
+	}
+
+	start := 0
+	if next != "" {
+		start = -1
+		for i, artifact := range build.Artifacts {
+			if artifact.Slug == next {
+				start = i
+				break
+			}
+		}
+		if start < 0 {
+			return errorBody(http.StatusBadRequest, "invalid next parameter")
+		}
+	}
+
+	end := start + s.spec.PageSize
+	if end > len(build.Artifacts) {
+		end = len(build.Artifacts)
+	}
+
+	response := api.ListBuildArtifactsResponse{
+		Data: []api.ArtifactListElementResponseModel{},
+		Paging: api.PagingModel{
+			TotalItemCount: int64(len(build.Artifacts)),
+			PageItemLimit:  uint(s.spec.PageSize),
+		},
+	}
+	for _, artifact := range build.Artifacts[start:end] {
+		response.Data = append(response.Data, api.ArtifactListElementResponseModel{Title: artifact.Title, Slug: artifact.Slug})
+	}
+	if end < len(build.Artifacts) {
+		response.Paging.Next = build.Artifacts[end].Slug
+	}
+
+	return jsonBody(response)
+}
+
+func (s *Server) showArtifact(baseURL, appSlug, buildSlug, artifactSlug string, expiredURL bool) (int, []byte) {
+	build, ok := s.spec.findBuild(appSlug, buildSlug)
+	if !ok {
+		return errorBody(http.StatusNotFound, "build not found")
+	}
+	artifact, ok := build.findArtifact(artifactSlug)
+	if !ok {
+		return errorBody(http.StatusNotFound, "artifact not found")
+	}
+
+	expires := time.Now().Add(downloadURLTTL)
+	if expiredURL {
+		expires = time.Now().Add(-time.Minute)
+	}
+
+	return jsonBody(api.ShowBuildArtifactResponse{Data: api.ArtifactResponseItemModel{
+		Title:         artifact.Title,
+		Slug:          artifact.Slug,
+		FileSizeBytes: int64(len(artifact.Content)),
+		DownloadURL:   downloadURL(baseURL, appSlug, buildSlug, artifactSlug, expires),
+	}})
+}
+
+func (s *Server) listBuilds(appSlug string, query url.Values) (int, []byte) {
+	app, ok := s.spec.Apps[appSlug]
+	if !ok {
+		return errorBody(http.StatusNotFound, "app not found")
+	}
+
+	builds := append([]Build{}, app.Builds...)
+	sort.SliceStable(builds, func(i, j int) bool {
+		return builds[i].BuildNumber > builds[j].BuildNumber
+	})
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	response := api.ListBuildsResponse{Data: []api.BuildListElementResponseModel{}}
+	for _, build := range builds {
+		if status := query.Get("status"); status != "" && status != strconv.Itoa(build.Status) {
+			continue
+		}
+		if branch := query.Get("branch"); branch != "" && branch != build.Branch {
+			continue
+		}
+		if workflow := query.Get("workflow"); workflow != "" && workflow != build.Workflow {
+			continue
+		}
+		if limit > 0 && len(response.Data) == limit {
+			break
+		}
+
+		response.Data = append(response.Data, api.BuildListElementResponseModel{
+			Slug:              build.Slug,
+			BuildNumber:       build.BuildNumber,
+			Branch:            build.Branch,
+			TriggeredWorkflow: build.Workflow,
+			Status:            build.Status,
+			FinishedAt:        build.FinishedAt,
+		})
+	}
+
+	return jsonBody(response)
+}
+
+func (s *Server) download(appSlug, buildSlug, artifactSlug string, query url.Values) (int, []byte) {
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || !hmac.Equal([]byte(query.Get("signature")), []byte(sign(appSlug, buildSlug, artifactSlug, expires))) {
+		return http.StatusForbidden, []byte("SignatureDoesNotMatch")
+	}
+	if time.Now().Unix() > expires {
+		return http.StatusForbidden, []byte("Request has expired")
+	}
+
+	build, ok := s.spec.findBuild(appSlug, buildSlug)
+	if !ok {
+		return http.StatusNotFound, []byte("NoSuchKey")
+	}
+	artifact, ok := build.findArtifact(artifactSlug)
+	if !ok {
+		return http.StatusNotFound, []byte("NoSuchKey")
+	}
+
+	return http.StatusOK, artifact.Content
+}
+
+// downloadURL returns a signed download URL, like the expiring download URLs of the real API
+func downloadURL(baseURL, appSlug, buildSlug, artifactSlug string, expires time.Time) string {
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("signature", sign(appSlug, buildSlug, artifactSlug, expires.Unix()))
+
+	return fmt.Sprintf("%s/download/%s/%s/%s?%s", baseURL, appSlug, buildSlug, url.PathEscape(artifactSlug), query.Encode())
+}
+
+func sign(appSlug, buildSlug, artifactSlug string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	_, _ = fmt.Fprintf(mac, "%s/%s/%s/%d", appSlug, buildSlug, artifactSlug, expires)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func jsonBody(v interface{}) (int, []byte) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return errorBody(http.StatusInternalServerError, err.Error())
+	}
+
+	return http.StatusOK, body
+}
+
+func errorBody(status int, message string) (int, []byte) {
+	body, _ := json.Marshal(map[string]string{"message": message})
+
+	return status, body
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	status, body := errorBody(status, message)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,200 @@
+package fakeapi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func testSpec() Spec {
+	var artifacts []Artifact
+	for i := 1; i <= 5; i++ {
+		artifacts = append(artifacts, Artifact{Slug: fmt.Sprintf("artifact-%d", i), Title: fmt.Sprintf("%d.txt", i), Content: []byte(fmt.Sprintf("content %d", i))})
+	}
+
+	return Spec{
+		Token:    "token",
+		PageSize: 2,
+		Apps: map[string]App{
+			"app-slug": {Builds: []Build{
+				{Slug: "build-1", BuildNumber: 1, Branch: "main", Workflow: "primary", Status: 1, Artifacts: artifacts},
+				{Slug: "build-2", BuildNumber: 2, Branch: "main", Workflow: "primary", Status: 2},
+				{Slug: "build-3", BuildNumber: 3, Branch: "feature", Workflow: "primary", Status: 1},
+			}},
+		},
+	}
+}
+
+func startServer(t *testing.T, spec Spec) (*Server, string) {
+	server := NewServer(spec)
+	baseURL := server.Start()
+	t.Cleanup(server.Close)
+
+	return server, baseURL
+}
+
+func get(t *testing.T, url, token string) (int, string, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	assert.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := ioutil.ReadAll(resp.Body)
+
+	return resp.StatusCode, string(body), err
+}
+
+func TestServer_WithAPIClient(t *testing.T) {
+	server, baseURL := startServer(t, testSpec())
+
+	client, err := api.NewDefaultBitriseAPIClient(baseURL, "token")
+	assert.NoError(t, err)
+
+	artifacts, err := client.ListBuildArtifacts("app-slug", "build-1")
+	assert.NoError(t, err)
+	assert.Len(t, artifacts, 5)
+	assert.Equal(t, 3, server.Requests(EndpointListArtifacts))
+
+	artifact, err := client.ShowBuildArtifact("app-slug", "build-1", "artifact-3")
+	assert.NoError(t, err)
+	assert.Equal(t, "3.txt", artifact.Title)
+
+	status, body, err := get(t, artifact.DownloadURL, "")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "content 3", body)
+
+	builds, err := client.ListBuilds("app-slug", api.BuildFilter{Branch: "main", Status: api.BuildStatusSuccessful, Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, builds, 1)
+	assert.Equal(t, "build-1", builds[0].Slug)
+}
+
+func TestServer_Unauthorized(t *testing.T) {
+	_, baseURL := startServer(t, testSpec())
+
+	status, _, err := get(t, baseURL+"/v0.2/apps/app-slug/builds/build-1/artifacts", "invalid")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
+
+func TestServer_StatusFaults(t *testing.T) {
+	server, baseURL := startServer(t, testSpec())
+	server.AddFault(TooManyRequests(EndpointListArtifacts, 1))
+	server.AddFault(ServerError(EndpointListArtifacts, http.StatusBadGateway, 1))
+
+	url := baseURL + "/v0.2/apps/app-slug/builds/build-1/artifacts"
+	var statuses []int
+	for i := 0; i < 3; i++ {
+		status, _, err := get(t, url, "token")
+		assert.NoError(t, err)
+		statuses = append(statuses, status)
+	}
+
+	assert.Equal(t, []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusOK}, statuses)
+	assert.Equal(t, 3, server.Requests(EndpointAny))
+}
+
+func TestServer_Latency(t *testing.T) {
+	server, baseURL := startServer(t, testSpec())
+	server.AddFault(Latency(EndpointAny, 50*time.Millisecond))
+
+	start := time.Now()
+	status, _, err := get(t, baseURL+"/v0.1/apps/app-slug/builds", "token")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(50*time.Millisecond))
+}
+
+func TestServer_TruncatedDownload(t *testing.T) {
+	server, baseURL := startServer(t, testSpec())
+	server.AddFault(TruncatedDownload("artifact-1", 1))
+
+	client, err := api.NewDefaultBitriseAPIClient(baseURL, "token")
+	assert.NoError(t, err)
+	artifact, err := client.ShowBuildArtifact("app-slug", "build-1", "artifact-1")
+	assert.NoError(t, err)
+
+	_, _, err = get(t, artifact.DownloadURL, "")
+	assert.Error(t, err)
+
+	status, body, err := get(t, artifact.DownloadURL, "")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "content 1", body)
+}
+
+func TestServer_ExpiredURLs(t *testing.T) {
+	server, baseURL := startServer(t, testSpec())
+	server.AddFault(ExpiredURLs(1))
+
+	client, err := api.NewDefaultBitriseAPIClient(baseURL, "token")
+	assert.NoError(t, err)
+
+	expired, err := client.ShowBuildArtifact("app-slug", "build-1", "artifact-1")
+	assert.NoError(t, err)
+	status, body, err := get(t, expired.DownloadURL, "")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Equal(t, "Request has expired", body)
+
+	valid, err := client.ShowBuildArtifact("app-slug", "build-1", "artifact-1")
+	assert.NoError(t, err)
+	status, _, err = get(t, valid.DownloadURL, "")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	status, _, err = get(t, valid.DownloadURL+"0", "")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, status)
+}
+
+func TestLoadSpec(t *testing.T) {
+	dir := t.TempDir()
+	buildDir := filepath.Join(dir, "app-slug", "build-1")
+	assert.NoError(t, os.MkdirAll(buildDir, 0o755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(buildDir, "app.apk"), []byte("apk"), 0o644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(buildDir, "build.json"), []byte(`{"build_number": 7, "branch": "main", "triggered_workflow": "android", "status": 1}`), 0o644))
+
+	spec, err := LoadSpec(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, Spec{Apps: map[string]App{
+		"app-slug": {Builds: []Build{{
+			Slug:        "build-1",
+			BuildNumber: 7,
+			Branch:      "main",
+			Workflow:    "android",
+			Status:      1,
+			Artifacts:   []Artifact{{Slug: "app.apk", Title: "app.apk", Content: []byte("apk")}},
+		}}},
+	}}, spec)
+}
+
+func TestLoadSpec_ExampleFixtures(t *testing.T) {
+	spec, err := LoadSpec(filepath.Join("testdata", "fixtures"))
+	assert.NoError(t, err)
+
+	builds := spec.Apps["example-app"].Builds
+	assert.Equal(t, 2, len(builds))
+	assert.Equal(t, "build-android", builds[0].Slug)
+	assert.Equal(t, "android", builds[0].Workflow)
+	assert.Equal(t, "app-debug.apk", builds[0].Artifacts[0].Title)
+	assert.Equal(t, "build-ios", builds[1].Slug)
+	assert.Equal(t, "app.ipa", builds[1].Artifacts[0].Title)
+}
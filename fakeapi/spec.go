@@ -0,0 +1,142 @@
+package fakeapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+)
+
+// buildInfoFileName is the optional build metadata file in a build directory of a fixture directory
+const buildInfoFileName = "build.json"
+
+// Spec describes the apps, builds and artifacts served by the fake server
+type Spec struct {
+	// Token is the expected access token, requests with a different token are rejected if it is not empty
+	Token string
+	// PageSize is the maximum number of artifacts in an artifact list page (defaults to 50)
+	PageSize int
+	Apps     map[string]App
+}
+
+type App struct {
+	Builds []Build
+}
+
+type Build struct {
+	Slug        string `json:"slug"`
+	BuildNumber int64  `json:"build_number"`
+	Branch      string `json:"branch"`
+	Workflow    string `json:"triggered_workflow"`
+	// Status is the status code of the build, 1 means successful
+	Status     int        `json:"status"`
+	FinishedAt string     `json:"finished_at"`
+	Artifacts  []Artifact `json:"-"`
+}
+
+type Artifact struct {
+	Slug    string
+	Title   string
+	Content []byte
+}
+
+// LoadSpec reads a fixture directory with the `<app slug>/<build slug>/<artifact file>` layout. The title and the
+// slug of an artifact is its file name. A build directory can contain a build.json file with the properties of the
+// build (build_number, branch, triggered_workflow, status, finished_at); builds without it are successful.
+func LoadSpec(dir string) (Spec, error) {
+	spec := Spec{Apps: map[string]App{}}
+
+	appDirs, err := subDirs(dir)
+	if err != nil {
+		return Spec{}, err
+	}
+	for _, appSlug := range appDirs {
+		buildDirs, err := subDirs(filepath.Join(dir, appSlug))
+		if err != nil {
+			return Spec{}, err
+		}
+
+		var app App
+		for _, buildSlug := range buildDirs {
+			build, err := loadBuild(filepath.Join(dir, appSlug, buildSlug))
+			if err != nil {
+				return Spec{}, err
+			}
+			build.Slug = buildSlug
+			app.Builds = append(app.Builds, build)
+		}
+		spec.Apps[appSlug] = app
+	}
+
+	return spec, nil
+}
+
+func loadBuild(dir string) (Build, error) {
+	build := Build{Status: 1}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return Build{}, err
+	}
+
+	for _, entry := range entries {
+		pth := filepath.Join(dir, entry.Name())
+		if entry.IsDir() {
+			continue
+		}
+
+		content, err := ioutil.ReadFile(pth)
+		if err != nil {
+			return Build{}, err
+		}
+
+		if entry.Name() == buildInfoFileName {
+			if err := json.Unmarshal(content, &build); err != nil {
+				return Build{}, fmt.Errorf("invalid build info (%s): %w", pth, err)
+			}
+			continue
+		}
+
+		build.Artifacts = append(build.Artifacts, Artifact{Slug: entry.Name(), Title: entry.Name(), Content: content})
+	}
+
+	return build, nil
+}
+
+func subDirs(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (s Spec) findBuild(appSlug, buildSlug string) (Build, bool) {
+	for _, build := range s.Apps[appSlug].Builds {
+		if build.Slug == buildSlug {
+			return build, true
+		}
+	}
+
+	return Build{}, false
+}
+
+func (b Build) findArtifact(artifactSlug string) (Artifact, bool) {
+	for _, artifact := range b.Artifacts {
+		if artifact.Slug == artifactSlug {
+			return artifact, true
+		}
+	}
+
+	return Artifact{}, false
+}
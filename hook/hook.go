@@ -0,0 +1,142 @@
+package hook
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/model"
+)
+
+// The properties of the artifact are passed to the hook command in these environment variables
+const (
+	PathEnvKey      = "BITRISE_PULLED_ARTIFACT_PATH"
+	TitleEnvKey     = "BITRISE_PULLED_ARTIFACT_TITLE"
+	StageEnvKey     = "BITRISE_PULLED_ARTIFACT_STAGE"
+	WorkflowEnvKey  = "BITRISE_PULLED_ARTIFACT_WORKFLOW"
+	BuildSlugEnvKey = "BITRISE_PULLED_ARTIFACT_BUILD_SLUG"
+)
+
+// Runner runs a shell command for each pulled artifact
+type Runner struct {
+	Command     string
+	Parallelism int
+	CmdFactory  command.Factory
+	Logger      log.Logger
+	// Context stops starting the commands once it is done (like at a step deadline), the running commands are not
+	// interrupted. context.Background() is used if it is nil.
+	Context context.Context
+}
+
+type Result struct {
+	Artifact model.PulledArtifact
+	Output   string
+	Error    error
+}
+
+type job struct {
+	index    int
+	artifact model.PulledArtifact
+}
+
+type indexedResult struct {
+	index  int
+	result Result
+}
+
+func NewRunner(cmd string, parallelism int, cmdFactory command.Factory, logger log.Logger) Runner {
+	return Runner{
+		Command:     cmd,
+		Parallelism: parallelism,
+		CmdFactory:  cmdFactory,
+		Logger:      logger,
+	}
+}
+
+// Run runs the hook command for the artifacts concurrently (at most Parallelism commands at a time), logs the outcome
+// for each artifact, and returns an error listing the artifacts for which the command failed
+func (r Runner) Run(artifacts []model.PulledArtifact) error {
+	results := r.runParallel(artifacts)
+
+	var failed []string
+	for _, res := range results {
+		if res.Error != nil {
+			r.Logger.Errorf("Post-download hook failed for %s: %s", res.Artifact.Path, res.Error)
+			failed = append(failed, res.Artifact.Path)
+		} else {
+			r.Logger.Donef("Post-download hook succeeded for %s", res.Artifact.Path)
+		}
+		if res.Output != "" {
+			r.Logger.Printf("%s", res.Output)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("post-download hook failed for %d artifact(s): %s", len(failed), strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// runParallel returns the results in the order of the artifacts
+func (r Runner) runParallel(artifacts []model.PulledArtifact) []Result {
+	jobs := make(chan job, len(artifacts))
+	results := make(chan indexedResult, len(artifacts))
+
+	parallelism := r.Parallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	for i := 0; i < parallelism; i++ {
+		go r.worker(jobs, results)
+	}
+
+	for i, artifact := range artifacts {
+		jobs <- job{index: i, artifact: artifact}
+	}
+	close(jobs)
+
+	hookResults := make([]Result, len(artifacts))
+	for i := 0; i < len(artifacts); i++ {
+		res := <-results
+		hookResults[res.index] = res.result
+	}
+
+	return hookResults
+}
+
+func (r Runner) worker(jobs <-chan job, results chan<- indexedResult) {
+	for j := range jobs {
+		if err := r.context().Err(); err != nil {
+			results <- indexedResult{index: j.index, result: Result{Artifact: j.artifact, Error: fmt.Errorf("not started: %w", err)}}
+			continue
+		}
+
+		cmd := r.CmdFactory.Create("bash", []string{"-c", r.Command}, &command.Opts{Env: artifactEnvs(j.artifact)})
+		r.Logger.Debugf("Running post-download hook for %s", j.artifact.Path)
+
+		output, err := cmd.RunAndReturnTrimmedCombinedOutput()
+
+		results <- indexedResult{index: j.index, result: Result{Artifact: j.artifact, Output: output, Error: err}}
+	}
+}
+
+func (r Runner) context() context.Context {
+	if r.Context == nil {
+		return context.Background()
+	}
+
+	return r.Context
+}
+
+func artifactEnvs(artifact model.PulledArtifact) []string {
+	return []string{
+		PathEnvKey + "=" + artifact.Path,
+		TitleEnvKey + "=" + artifact.Title,
+		StageEnvKey + "=" + artifact.Stage,
+		WorkflowEnvKey + "=" + artifact.Workflow,
+		BuildSlugEnvKey + "=" + artifact.BuildSlug,
+	}
+}
@@ -0,0 +1,122 @@
+package hook
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/env"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunner_PassesArtifactProperties(t *testing.T) {
+	dir := t.TempDir()
+	artifacts := []model.PulledArtifact{
+		{Path: filepath.Join(dir, "app.apk"), Title: "app.apk", Stage: "build", Workflow: "android", BuildSlug: "build-1"},
+		{Path: filepath.Join(dir, "app.ipa"), Title: "app.ipa", Stage: "build", Workflow: "ios", BuildSlug: "build-2"},
+	}
+	hookCmd := `echo "$BITRISE_PULLED_ARTIFACT_TITLE $BITRISE_PULLED_ARTIFACT_STAGE $BITRISE_PULLED_ARTIFACT_WORKFLOW $BITRISE_PULLED_ARTIFACT_BUILD_SLUG" > "$BITRISE_PULLED_ARTIFACT_PATH.hook"`
+
+	runner := NewRunner(hookCmd, 2, command.NewFactory(env.NewRepository()), log.NewLogger())
+	err := runner.Run(artifacts)
+	assert.NoError(t, err)
+
+	for _, artifact := range artifacts {
+		content, err := ioutil.ReadFile(artifact.Path + ".hook")
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("%s %s %s %s\n", artifact.Title, artifact.Stage, artifact.Workflow, artifact.BuildSlug), string(content))
+	}
+}
+
+func TestRunner_ReportsFailuresPerArtifact(t *testing.T) {
+	artifacts := []model.PulledArtifact{
+		{Path: "a.txt"},
+		{Path: "bad-b.txt"},
+		{Path: "c.txt"},
+		{Path: "bad-d.txt"},
+	}
+	hookCmd := `case "$BITRISE_PULLED_ARTIFACT_PATH" in bad-*) echo "invalid signature"; exit 1;; esac`
+
+	runner := NewRunner(hookCmd, 3, command.NewFactory(env.NewRepository()), log.NewLogger())
+	results := runner.runParallel(artifacts)
+
+	assert.Len(t, results, len(artifacts))
+	for i, res := range results {
+		assert.Equal(t, artifacts[i], res.Artifact)
+		if strings.HasPrefix(res.Artifact.Path, "bad-") {
+			assert.EqualError(t, res.Error, "exit status 1")
+			assert.Equal(t, "invalid signature", res.Output)
+		} else {
+			assert.NoError(t, res.Error)
+		}
+	}
+
+	err := runner.Run(artifacts)
+	assert.EqualError(t, err, "post-download hook failed for 2 artifact(s): bad-b.txt, bad-d.txt")
+}
+
+type countingFactory struct {
+	running, maxRunning int32
+}
+
+func (f *countingFactory) Create(name string, args []string, opts *command.Opts) command.Command {
+	return countingCommand{Command: command.NewFactory(env.NewRepository()).Create(name, args, opts), factory: f}
+}
+
+type countingCommand struct {
+	command.Command
+	factory *countingFactory
+}
+
+func (c countingCommand) RunAndReturnTrimmedCombinedOutput() (string, error) {
+	running := atomic.AddInt32(&c.factory.running, 1)
+	defer atomic.AddInt32(&c.factory.running, -1)
+	for {
+		maxRunning := atomic.LoadInt32(&c.factory.maxRunning)
+		if running <= maxRunning || atomic.CompareAndSwapInt32(&c.factory.maxRunning, maxRunning, running) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	return "", nil
+}
+
+func TestRunner_BoundedParallelism(t *testing.T) {
+	var artifacts []model.PulledArtifact
+	for i := 0; i < 12; i++ {
+		artifacts = append(artifacts, model.PulledArtifact{Path: fmt.Sprintf("%d.txt", i)})
+	}
+
+	factory := &countingFactory{}
+	runner := NewRunner("true", 3, factory, log.NewLogger())
+
+	err := runner.Run(artifacts)
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, factory.maxRunning, int32(3))
+	assert.Greater(t, factory.maxRunning, int32(1))
+}
+
+func TestRunner_DoesNotStartAfterContextIsDone(t *testing.T) {
+	artifacts := []model.PulledArtifact{{Path: "a.txt"}, {Path: "b.txt"}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	factory := &countingFactory{}
+	runner := NewRunner("true", 2, factory, log.NewLogger())
+	runner.Context = ctx
+
+	results := runner.runParallel(artifacts)
+	for _, res := range results {
+		assert.EqualError(t, res.Error, "not started: context canceled")
+	}
+	assert.Equal(t, int32(0), factory.maxRunning)
+}
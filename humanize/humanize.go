@@ -0,0 +1,72 @@
+package humanize
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bytes formats a size in bytes with a binary unit, like 1.5 MB
+func Bytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	value := float64(size) / unit
+	for _, prefix := range []string{"KB", "MB"} {
+		if value < unit {
+			return fmt.Sprintf("%.1f %s", value, prefix)
+		}
+		value /= unit
+	}
+
+	return fmt.Sprintf("%.1f GB", value)
+}
+
+var byteUnits = []struct {
+	suffix     string
+	multiplier float64
+}{
+	{"GB", 1024 * 1024 * 1024},
+	{"MB", 1024 * 1024},
+	{"KB", 1024},
+	{"B", 1},
+}
+
+// ParseRate parses a transfer rate with a binary unit, like 50MB/s or 512KB/s, into bytes per second
+func ParseRate(rate string) (int64, error) {
+	value := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(rate)), "/S")
+
+	for _, unit := range byteUnits {
+		if !strings.HasSuffix(value, unit.suffix) {
+			continue
+		}
+
+		number, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(value, unit.suffix)), 64)
+		if err != nil || number <= 0 || math.IsInf(number, 0) {
+			break
+		}
+
+		// fractions of a byte can not be transferred, and the limiter needs at least 1 byte per second
+		bytesPerSecond := number * unit.multiplier
+		if bytesPerSecond < 1 || bytesPerSecond >= math.MaxInt64 {
+			break
+		}
+
+		return int64(bytesPerSecond), nil
+	}
+
+	return 0, fmt.Errorf("invalid transfer rate: %s", rate)
+}
+
+// Duration formats a duration rounded to milliseconds below a second, and to tenths of a second above
+func Duration(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+
+	return d.Round(100 * time.Millisecond).String()
+}
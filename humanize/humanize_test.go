@@ -0,0 +1,55 @@
+package humanize
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBytes(t *testing.T) {
+	assert.Equal(t, "0 B", Bytes(0))
+	assert.Equal(t, "1023 B", Bytes(1023))
+	assert.Equal(t, "1.5 KB", Bytes(1536))
+	assert.Equal(t, "2.0 MB", Bytes(2*1024*1024))
+	assert.Equal(t, "3.0 GB", Bytes(3*1024*1024*1024))
+	assert.Equal(t, "2048.0 GB", Bytes(2*1024*1024*1024*1024))
+}
+
+func TestDuration(t *testing.T) {
+	assert.Equal(t, "12ms", Duration(12345*time.Microsecond))
+	assert.Equal(t, "1.5s", Duration(1523*time.Millisecond))
+	assert.Equal(t, "2m3.4s", Duration(123400*time.Millisecond))
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		rate    string
+		want    int64
+		wantErr bool
+	}{
+		{rate: "50MB/s", want: 50 * 1024 * 1024},
+		{rate: "1.5 GB/s", want: 1536 * 1024 * 1024},
+		{rate: "512kb/s", want: 512 * 1024},
+		{rate: "100MB", want: 100 * 1024 * 1024},
+		{rate: "4096B/s", want: 4096},
+		{rate: "50", wantErr: true},
+		{rate: "0MB/s", wantErr: true},
+		{rate: "fastMB/s", wantErr: true},
+		{rate: "10TB/s", wantErr: true},
+		{rate: "0.5B/s", wantErr: true},
+		{rate: "99999999999GB/s", wantErr: true},
+		{rate: "1.5B/s", want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rate, func(t *testing.T) {
+			got, err := ParseRate(tt.rate)
+			if tt.wantErr {
+				assert.EqualError(t, err, "invalid transfer rate: "+tt.rate)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package junit
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/model"
+)
+
+// The source of the merged test suites is recorded in these properties
+const (
+	StagePropertyName     = "bitrise.stage"
+	WorkflowPropertyName  = "bitrise.workflow"
+	BuildSlugPropertyName = "bitrise.build_slug"
+)
+
+type testSuites struct {
+	XMLName  xml.Name    `xml:"testsuites"`
+	Name     string      `xml:"name,attr,omitempty"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     string      `xml:"time,attr"`
+	Suites   []testSuite `xml:"testsuite"`
+}
+
+type testSuite struct {
+	XMLName    xml.Name    `xml:"testsuite"`
+	Attrs      []xml.Attr  `xml:",any,attr"`
+	Properties *properties `xml:"properties"`
+	TestCases  []testCase  `xml:"testcase"`
+	Suites     []testSuite `xml:"testsuite"`
+	SystemOut  *output     `xml:"system-out"`
+	SystemErr  *output     `xml:"system-err"`
+}
+
+type properties struct {
+	Properties []property `xml:"property"`
+}
+
+type property struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type testCase struct {
+	XMLName xml.Name   `xml:"testcase"`
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Content string     `xml:",innerxml"`
+}
+
+type output struct {
+	Content string `xml:",chardata"`
+}
+
+// Totals are the recomputed counts of a merged report
+type Totals struct {
+	Tests    int
+	Failures int
+	Errors   int
+	Skipped  int
+	Time     float64
+}
+
+func (t *Totals) add(other Totals) {
+	t.Tests += other.Tests
+	t.Failures += other.Failures
+	t.Errors += other.Errors
+	t.Skipped += other.Skipped
+	t.Time += other.Time
+}
+
+// MergeFiles merges the JUnit XML reports into one report written to the output path. The test suites are tagged
+// with the stage, workflow and build of the report, and the totals are recomputed from the test cases.
+func MergeFiles(reports []model.PulledArtifact, outputPath string) (Totals, error) {
+	merged := testSuites{Name: "merged"}
+
+	for _, report := range reports {
+		content, err := ioutil.ReadFile(report.Path)
+		if err != nil {
+			return Totals{}, err
+		}
+
+		suites, err := parseSuites(content)
+		if err != nil {
+			return Totals{}, fmt.Errorf("%s is not a valid JUnit XML report: %w", report.Path, err)
+		}
+
+		for _, suite := range suites {
+			suite.tag(report)
+			merged.Suites = append(merged.Suites, suite)
+		}
+	}
+
+	var totals Totals
+	for i := range merged.Suites {
+		totals.add(merged.Suites[i].recompute())
+	}
+	merged.Tests = totals.Tests
+	merged.Failures = totals.Failures
+	merged.Errors = totals.Errors
+	merged.Skipped = totals.Skipped
+	merged.Time = formatTime(totals.Time)
+
+	content, err := xml.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		return Totals{}, err
+	}
+	content = append([]byte(xml.Header), content...)
+
+	if err := ioutil.WriteFile(outputPath, content, 0o644); err != nil {
+		return Totals{}, err
+	}
+
+	return totals, nil
+}
+
+// parseSuites returns the test suites of a report with either a testsuites or a testsuite root element
+func parseSuites(content []byte) ([]testSuite, error) {
+	root, err := rootElementName(content)
+	if err != nil {
+		return nil, err
+	}
+
+	switch root {
+	case "testsuites":
+		var suites testSuites
+		if err := xml.Unmarshal(content, &suites); err != nil {
+			return nil, err
+		}
+		return suites.Suites, nil
+	case "testsuite":
+		var suite testSuite
+		if err := xml.Unmarshal(content, &suite); err != nil {
+			return nil, err
+		}
+		return []testSuite{suite}, nil
+	default:
+		return nil, fmt.Errorf("unexpected root element: %s", root)
+	}
+}
+
+func rootElementName(content []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return "", fmt.Errorf("missing root element")
+		}
+		if err != nil {
+			return "", err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+func (s *testSuite) tag(report model.PulledArtifact) {
+	if s.Properties == nil {
+		s.Properties = &properties{}
+	}
+	s.Properties.Properties = append(s.Properties.Properties,
+		property{Name: StagePropertyName, Value: report.Stage},
+		property{Name: WorkflowPropertyName, Value: report.Workflow},
+		property{Name: BuildSlugPropertyName, Value: report.BuildSlug},
+	)
+}
+
+// recompute updates the count attributes of the suite (and its nested suites) from the test cases, and returns the totals
+func (s *testSuite) recompute() Totals {
+	var totals Totals
+	for _, tc := range s.TestCases {
+		totals.add(tc.totals())
+	}
+	for i := range s.Suites {
+		nested := s.Suites[i].recompute()
+		totals.add(nested)
+	}
+
+	// the time of a suite can include setup and teardown, keep it if it is defined
+	if suiteTime, ok := s.attr("time"); ok {
+		if t, err := strconv.ParseFloat(suiteTime, 64); err == nil {
+			totals.Time = t
+		}
+	}
+
+	s.setAttr("tests", strconv.Itoa(totals.Tests))
+	s.setAttr("failures", strconv.Itoa(totals.Failures))
+	s.setAttr("errors", strconv.Itoa(totals.Errors))
+	s.setAttr("skipped", strconv.Itoa(totals.Skipped))
+	s.setAttr("time", formatTime(totals.Time))
+
+	return totals
+}
+
+func (s testSuite) attr(name string) (string, bool) {
+	for _, attr := range s.Attrs {
+		if attr.Name.Local == name {
+			return attr.Value, true
+		}
+	}
+
+	return "", false
+}
+
+func (s *testSuite) setAttr(name, value string) {
+	for i, attr := range s.Attrs {
+		if attr.Name.Local == name {
+			s.Attrs[i].Value = value
+			return
+		}
+	}
+
+	s.Attrs = append(s.Attrs, xml.Attr{Name: xml.Name{Local: name}, Value: value})
+}
+
+// totals returns the counts of a single test case based on its failure, error and skipped child elements
+func (tc testCase) totals() Totals {
+	totals := Totals{Tests: 1}
+	for _, attr := range tc.Attrs {
+		if attr.Name.Local == "time" {
+			if t, err := strconv.ParseFloat(attr.Value, 64); err == nil {
+				totals.Time = t
+			}
+		}
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader([]byte(tc.Content)))
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				switch t.Name.Local {
+				case "failure":
+					totals.Failures = 1
+				case "error":
+					totals.Errors = 1
+				case "skipped":
+					totals.Skipped = 1
+				}
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+	}
+
+	return totals
+}
+
+func formatTime(t float64) string {
+	return strconv.FormatFloat(t, 'f', 3, 64)
+}
@@ -0,0 +1,89 @@
+package junit
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/model"
+	"github.com/stretchr/testify/assert"
+)
+
+const shard1Report = `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="shard 1" tests="99" failures="0">
+  <testsuite name="LoginTests" tests="2" failures="0" time="1.5">
+    <testcase name="testLogin" classname="LoginTests" time="1.0"/>
+    <testcase name="testLogout" classname="LoginTests" time="0.5">
+      <failure message="expected true">LoginTests.swift:42</failure>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+
+const shard2Report = `<?xml version="1.0" encoding="UTF-8"?>
+<testsuite name="CartTests" hostname="mac-1">
+  <properties>
+    <property name="device" value="iPhone 15"/>
+  </properties>
+  <testcase name="testAdd" classname="CartTests" time="0.25"><skipped/></testcase>
+  <testcase name="testRemove" classname="CartTests" time="0.25"><error type="crash"/><system-out>log</system-out></testcase>
+  <system-out>suite output</system-out>
+</testsuite>
+`
+
+const expectedReport = `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="merged" tests="4" failures="1" errors="1" skipped="1" time="2.000">
+  <testsuite name="LoginTests" tests="2" failures="1" time="1.500" errors="0" skipped="0">
+    <properties>
+      <property name="bitrise.stage" value="test"></property>
+      <property name="bitrise.workflow" value="ui-test"></property>
+      <property name="bitrise.build_slug" value="build-1"></property>
+    </properties>
+    <testcase name="testLogin" classname="LoginTests" time="1.0"></testcase>
+    <testcase name="testLogout" classname="LoginTests" time="0.5">
+      <failure message="expected true">LoginTests.swift:42</failure>
+    </testcase>
+  </testsuite>
+  <testsuite name="CartTests" hostname="mac-1" tests="2" failures="0" errors="1" skipped="1" time="0.500">
+    <properties>
+      <property name="device" value="iPhone 15"></property>
+      <property name="bitrise.stage" value="test"></property>
+      <property name="bitrise.workflow" value="ui-test"></property>
+      <property name="bitrise.build_slug" value="build-2"></property>
+    </properties>
+    <testcase name="testAdd" classname="CartTests" time="0.25"><skipped/></testcase>
+    <testcase name="testRemove" classname="CartTests" time="0.25"><error type="crash"/><system-out>log</system-out></testcase>
+    <system-out>suite output</system-out>
+  </testsuite>
+</testsuites>`
+
+func TestMergeFiles(t *testing.T) {
+	dir := t.TempDir()
+	shard1 := filepath.Join(dir, "shard1.xml")
+	shard2 := filepath.Join(dir, "shard2.xml")
+	assert.NoError(t, ioutil.WriteFile(shard1, []byte(shard1Report), 0o644))
+	assert.NoError(t, ioutil.WriteFile(shard2, []byte(shard2Report), 0o644))
+
+	outputPath := filepath.Join(dir, "merged.xml")
+	totals, err := MergeFiles([]model.PulledArtifact{
+		{Path: shard1, Stage: "test", Workflow: "ui-test", BuildSlug: "build-1"},
+		{Path: shard2, Stage: "test", Workflow: "ui-test", BuildSlug: "build-2"},
+	}, outputPath)
+
+	assert.NoError(t, err)
+	assert.Equal(t, Totals{Tests: 4, Failures: 1, Errors: 1, Skipped: 1, Time: 2}, totals)
+
+	content, err := ioutil.ReadFile(outputPath)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedReport, string(content))
+}
+
+func TestMergeFiles_InvalidReport(t *testing.T) {
+	dir := t.TempDir()
+	report := filepath.Join(dir, "report.xml")
+	assert.NoError(t, ioutil.WriteFile(report, []byte(`<coverage line-rate="0.5"/>`), 0o644))
+
+	_, err := MergeFiles([]model.PulledArtifact{{Path: report}}, filepath.Join(dir, "merged.xml"))
+
+	assert.EqualError(t, err, report+" is not a valid JUnit XML report: unexpected root element: coverage")
+}
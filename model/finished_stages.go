@@ -1,5 +1,11 @@
 package model
 
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
 type FinishedStages []Stage
 
 type Stage struct {
@@ -10,4 +16,82 @@ type Stage struct {
 type Workflow struct {
 	ExternalId string `json:"external_id"`
 	Name       string `json:"name"`
+	// StartedAt and FinishedAt are nil while the workflow has not started or finished
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+// UnmarshalJSON parses the RFC3339 timestamps of the workflow, an empty timestamp is left nil
+func (w *Workflow) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		ExternalId string `json:"external_id"`
+		Name       string `json:"name"`
+		StartedAt  string `json:"started_at"`
+		FinishedAt string `json:"finished_at"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	startedAt, err := parseTime(raw.StartedAt)
+	if err != nil {
+		return fmt.Errorf("invalid started_at of workflow %s (%s): %w", raw.Name, raw.ExternalId, err)
+	}
+	finishedAt, err := parseTime(raw.FinishedAt)
+	if err != nil {
+		return fmt.Errorf("invalid finished_at of workflow %s (%s): %w", raw.Name, raw.ExternalId, err)
+	}
+
+	*w = Workflow{
+		ExternalId: raw.ExternalId,
+		Name:       raw.Name,
+		StartedAt:  startedAt,
+		FinishedAt: finishedAt,
+	}
+
+	return nil
+}
+
+// IsNewerRunThan reports whether the workflow run finished (or if the finish times are unknown or equal, started) later than the other run
+func (w Workflow) IsNewerRunThan(other Workflow) bool {
+	finishedAt, otherFinishedAt := timeOrZero(w.FinishedAt), timeOrZero(other.FinishedAt)
+	if !finishedAt.Equal(otherFinishedAt) {
+		return finishedAt.After(otherFinishedAt)
+	}
+
+	return timeOrZero(w.StartedAt).After(timeOrZero(other.StartedAt))
+}
+
+// FindBuild returns the stage name and the workflow which ran as the given build
+func (fs FinishedStages) FindBuild(buildSlug string) (string, Workflow, bool) {
+	for _, stage := range fs {
+		for _, wf := range stage.Workflows {
+			if wf.ExternalId == buildSlug {
+				return stage.Name, wf, true
+			}
+		}
+	}
+
+	return "", Workflow{}, false
+}
+
+func parseTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+func timeOrZero(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+
+	return *t
 }
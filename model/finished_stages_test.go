@@ -0,0 +1,57 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkflow_UnmarshalJSON(t *testing.T) {
+	var stages FinishedStages
+	err := json.Unmarshal([]byte(`[{"name": "build", "workflows": [
+		{"external_id": "build-1", "name": "ios", "started_at": "2022-05-24T20:00:00Z", "finished_at": ""},
+		{"external_id": "build-2", "name": "ios"}
+	]}]`), &stages)
+	assert.NoError(t, err)
+
+	started := time.Date(2022, 5, 24, 20, 0, 0, 0, time.UTC)
+	assert.Equal(t, Workflow{ExternalId: "build-1", Name: "ios", StartedAt: &started}, stages[0].Workflows[0])
+	assert.Equal(t, Workflow{ExternalId: "build-2", Name: "ios"}, stages[0].Workflows[1])
+}
+
+func TestWorkflow_UnmarshalJSON_InvalidTime(t *testing.T) {
+	var stages FinishedStages
+	err := json.Unmarshal([]byte(`[{"name": "build", "workflows": [
+		{"external_id": "build-1", "name": "ios", "started_at": "2022-05-24T20:00:00Z", "finished_at": "not a time"}
+	]}]`), &stages)
+
+	assert.EqualError(t, err, `invalid finished_at of workflow ios (build-1): parsing time "not a time" as "2006-01-02T15:04:05Z07:00": cannot parse "not a time" as "2006"`)
+}
+
+func TestWorkflow_MarshalJSON_RoundTrip(t *testing.T) {
+	finished := time.Date(2022, 5, 24, 20, 10, 0, 0, time.UTC)
+	stages := FinishedStages{{Name: "build", Workflows: []Workflow{{ExternalId: "build-1", Name: "ios", FinishedAt: &finished}}}}
+
+	data, err := json.Marshal(stages)
+	assert.NoError(t, err)
+
+	var parsed FinishedStages
+	assert.NoError(t, json.Unmarshal(data, &parsed))
+	assert.Equal(t, stages, parsed)
+}
+
+func TestWorkflow_IsNewerRunThan(t *testing.T) {
+	at := func(hour, min int) *time.Time {
+		t := time.Date(2022, 5, 24, hour, min, 0, 0, time.UTC)
+		return &t
+	}
+	older := Workflow{StartedAt: at(20, 0), FinishedAt: at(20, 10)}
+	newer := Workflow{StartedAt: at(20, 20), FinishedAt: at(20, 30)}
+	unfinished := Workflow{StartedAt: at(20, 40)}
+
+	assert.True(t, newer.IsNewerRunThan(older))
+	assert.False(t, older.IsNewerRunThan(newer))
+	assert.True(t, newer.IsNewerRunThan(unfinished))
+}
@@ -0,0 +1,43 @@
+package model
+
+import "sort"
+
+// PulledArtifact is a downloaded artifact together with the build, stage and workflow it was generated by
+type PulledArtifact struct {
+	Path      string
+	Title     string
+	BuildSlug string
+	Stage     string
+	Workflow  string
+}
+
+// SortPulledArtifacts orders the artifacts by the stage and the workflow (in the order of the finished stages)
+// which generated them, then by title. Artifacts of builds outside of the finished stages are placed last.
+func SortPulledArtifacts(artifacts []PulledArtifact, finishedStages FinishedStages) {
+	buildOrder := make(map[string]int)
+	for _, stage := range finishedStages {
+		for _, wf := range stage.Workflows {
+			if _, ok := buildOrder[wf.ExternalId]; !ok {
+				buildOrder[wf.ExternalId] = len(buildOrder)
+			}
+		}
+	}
+
+	position := func(artifact PulledArtifact) int {
+		if i, ok := buildOrder[artifact.BuildSlug]; ok {
+			return i
+		}
+		return len(buildOrder)
+	}
+
+	sort.SliceStable(artifacts, func(i, j int) bool {
+		pi, pj := position(artifacts[i]), position(artifacts[j])
+		if pi != pj {
+			return pi < pj
+		}
+		if artifacts[i].Title != artifacts[j].Title {
+			return artifacts[i].Title < artifacts[j].Title
+		}
+		return artifacts[i].Path < artifacts[j].Path
+	})
+}
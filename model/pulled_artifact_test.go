@@ -0,0 +1,29 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortPulledArtifacts(t *testing.T) {
+	finishedStages := FinishedStages{
+		{Name: "build", Workflows: []Workflow{{ExternalId: "build-ios", Name: "ios"}, {ExternalId: "build-android", Name: "android"}}},
+		{Name: "test", Workflows: []Workflow{{ExternalId: "build-test", Name: "unit-test"}}},
+	}
+	artifacts := []PulledArtifact{
+		{Path: "other.txt", Title: "other.txt", BuildSlug: "build-other"},
+		{Path: "report.xml", Title: "report.xml", BuildSlug: "build-test"},
+		{Path: "app.apk", Title: "app.apk", BuildSlug: "build-android"},
+		{Path: "app.ipa", Title: "app.ipa", BuildSlug: "build-ios"},
+		{Path: "app.dSYM.zip", Title: "app.dSYM.zip", BuildSlug: "build-ios"},
+	}
+
+	SortPulledArtifacts(artifacts, finishedStages)
+
+	var paths []string
+	for _, artifact := range artifacts {
+		paths = append(paths, artifact.Path)
+	}
+	assert.Equal(t, []string{"app.dSYM.zip", "app.ipa", "app.apk", "report.xml", "other.txt"}, paths)
+}
@@ -0,0 +1,77 @@
+package redact
+
+import (
+	"fmt"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+type logger struct {
+	logger   log.Logger
+	redactor Redactor
+}
+
+// Logger returns a logger redacting every message before passing it to the given logger
+func (r Redactor) Logger(l log.Logger) log.Logger {
+	return logger{logger: l, redactor: r}
+}
+
+func (l logger) message(format string, v ...interface{}) string {
+	return l.redactor.String(fmt.Sprintf(format, v...))
+}
+
+func (l logger) Infof(format string, v ...interface{}) {
+	l.logger.Infof("%s", l.message(format, v...))
+}
+
+func (l logger) Warnf(format string, v ...interface{}) {
+	l.logger.Warnf("%s", l.message(format, v...))
+}
+
+func (l logger) Printf(format string, v ...interface{}) {
+	l.logger.Printf("%s", l.message(format, v...))
+}
+
+func (l logger) Donef(format string, v ...interface{}) {
+	l.logger.Donef("%s", l.message(format, v...))
+}
+
+func (l logger) Debugf(format string, v ...interface{}) {
+	l.logger.Debugf("%s", l.message(format, v...))
+}
+
+func (l logger) Errorf(format string, v ...interface{}) {
+	l.logger.Errorf("%s", l.message(format, v...))
+}
+
+func (l logger) TInfof(format string, v ...interface{}) {
+	l.logger.TInfof("%s", l.message(format, v...))
+}
+
+func (l logger) TWarnf(format string, v ...interface{}) {
+	l.logger.TWarnf("%s", l.message(format, v...))
+}
+
+func (l logger) TPrintf(format string, v ...interface{}) {
+	l.logger.TPrintf("%s", l.message(format, v...))
+}
+
+func (l logger) TDonef(format string, v ...interface{}) {
+	l.logger.TDonef("%s", l.message(format, v...))
+}
+
+func (l logger) TDebugf(format string, v ...interface{}) {
+	l.logger.TDebugf("%s", l.message(format, v...))
+}
+
+func (l logger) TErrorf(format string, v ...interface{}) {
+	l.logger.TErrorf("%s", l.message(format, v...))
+}
+
+func (l logger) Println() {
+	l.logger.Println()
+}
+
+func (l logger) EnableDebugLog(enable bool) {
+	l.logger.EnableDebugLog(enable)
+}
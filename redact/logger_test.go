@@ -0,0 +1,35 @@
+package redact
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type messageLogger struct {
+	log.Logger
+	messages []string
+}
+
+func (l *messageLogger) Printf(format string, v ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, v...))
+}
+
+func (l *messageLogger) Errorf(format string, v ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, v...))
+}
+
+func TestRedactor_Logger(t *testing.T) {
+	messages := &messageLogger{}
+	logger := New("secret-token").Logger(messages)
+
+	logger.Printf("Failed to download artifact from %s", "https://storage.example.com/a.apk?signature=abc")
+	logger.Errorf("token: %s, progress: 100%%", "secret-token")
+
+	assert.Equal(t, []string{
+		"Failed to download artifact from https://storage.example.com/a.apk?[REDACTED]",
+		"token: [REDACTED], progress: 100%",
+	}, messages.messages)
+}
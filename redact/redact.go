@@ -0,0 +1,134 @@
+package redact
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	masked      = "[REDACTED]"
+	queryMasked = "?" + masked
+)
+
+// apiQueryParams are the query parameters of the Bitrise API requests. A URL with any other query parameter (like the
+// signature of an expiring download URL) is considered signed, and its query string is stripped.
+var apiQueryParams = map[string]bool{
+	"next":     true,
+	"sort_by":  true,
+	"status":   true,
+	"branch":   true,
+	"workflow": true,
+	"limit":    true,
+}
+
+// IsAPIQueryParam reports whether the query parameter is a Bitrise API parameter, which holds no credentials
+func IsAPIQueryParam(key string) bool {
+	return apiQueryParams[key]
+}
+
+var urlPattern = regexp.MustCompile(`https?://[^\s"'<>]+`)
+
+// credentialHeaders are the headers whose whole value is a credential
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// Redactor removes the secrets and the signed URL query strings from the log messages and errors
+type Redactor struct {
+	secrets []string
+}
+
+// New returns a redactor masking the given secrets (like the API access token) wherever they appear
+func New(secrets ...string) Redactor {
+	var nonEmpty []string
+	for _, secret := range secrets {
+		if secret != "" {
+			nonEmpty = append(nonEmpty, secret)
+		}
+	}
+
+	return Redactor{secrets: nonEmpty}
+}
+
+// String strips the query string of the signed URLs and masks the secrets
+func (r Redactor) String(s string) string {
+	s = urlPattern.ReplaceAllStringFunc(s, strippedURL)
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, masked)
+	}
+
+	return s
+}
+
+// Header returns a copy of the header with the credential headers masked and the other values redacted like String
+func (r Redactor) Header(header http.Header) http.Header {
+	if header == nil {
+		return nil
+	}
+
+	redacted := make(http.Header, len(header))
+	for key, values := range header {
+		redacted[key] = make([]string, len(values))
+		for i, value := range values {
+			redacted[key][i] = r.String(value)
+		}
+	}
+	for _, key := range credentialHeaders {
+		if values := redacted.Values(key); len(values) > 0 {
+			redacted.Del(key)
+			for range values {
+				redacted.Add(key, masked)
+			}
+		}
+	}
+
+	return redacted
+}
+
+// Error returns the error with a redacted message, the original error is still available with errors.Unwrap
+func (r Redactor) Error(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	message := err.Error()
+	redactedMessage := r.String(message)
+	if redactedMessage == message {
+		return err
+	}
+
+	return redactedError{err: err, message: redactedMessage}
+}
+
+type redactedError struct {
+	err     error
+	message string
+}
+
+func (e redactedError) Error() string {
+	return e.message
+}
+
+func (e redactedError) Unwrap() error {
+	return e.err
+}
+
+func strippedURL(rawURL string) string {
+	i := strings.Index(rawURL, "?")
+	if i < 0 {
+		return rawURL
+	}
+	base, rawQuery := rawURL[:i], rawURL[i+1:]
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return base + queryMasked
+	}
+	for key := range query {
+		if !IsAPIQueryParam(key) {
+			return base + queryMasked
+		}
+	}
+
+	return rawURL
+}
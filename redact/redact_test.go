@@ -0,0 +1,85 @@
+package redact
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactor_String(t *testing.T) {
+	redactor := New("secret-token", "")
+
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{
+			name:    "signed URL",
+			message: "GET https://storage.example.com/a.apk?X-Amz-Signature=abc&X-Amz-Expires=600 failed",
+			want:    "GET https://storage.example.com/a.apk?[REDACTED] failed",
+		},
+		{
+			name:    "API URL",
+			message: "request to https://api.bitrise.io/v0.2/apps/a/builds/b/artifacts?next=slug-2 failed",
+			want:    "request to https://api.bitrise.io/v0.2/apps/a/builds/b/artifacts?next=slug-2 failed",
+		},
+		{
+			name:    "URL without query",
+			message: "Artifact downloaded from https://storage.example.com/a.apk",
+			want:    "Artifact downloaded from https://storage.example.com/a.apk",
+		},
+		{
+			name:    "quoted URL",
+			message: `Get "https://storage.example.com/a.apk?signature=abc": EOF`,
+			want:    `Get "https://storage.example.com/a.apk?[REDACTED]": EOF`,
+		},
+		{
+			name:    "token",
+			message: "Authorization: Bearer secret-token",
+			want:    "Authorization: Bearer [REDACTED]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, redactor.String(tt.message))
+		})
+	}
+}
+
+func TestRedactor_Error(t *testing.T) {
+	redactor := New("secret-token")
+
+	assert.NoError(t, redactor.Error(nil))
+
+	unchanged := errors.New("not found")
+	assert.Equal(t, unchanged, redactor.Error(unchanged))
+
+	cause := errors.New("token secret-token is invalid")
+	err := redactor.Error(fmt.Errorf("download failed: %w", cause))
+	assert.EqualError(t, err, "download failed: token [REDACTED] is invalid")
+	assert.True(t, errors.Is(err, cause))
+}
+
+func TestRedactor_Header(t *testing.T) {
+	redactor := New("secret-token")
+
+	header := http.Header{
+		"Content-Type": {"application/json"},
+		"Location":     {"https://storage.example.com/a.apk?signature=abc"},
+		"Set-Cookie":   {"session=abc", "tracking=def"},
+		"X-Debug":      {"token secret-token"},
+	}
+
+	assert.Equal(t, http.Header{
+		"Content-Type": {"application/json"},
+		"Location":     {"https://storage.example.com/a.apk?[REDACTED]"},
+		"Set-Cookie":   {"[REDACTED]", "[REDACTED]"},
+		"X-Debug":      {"token [REDACTED]"},
+	}, redactor.Header(header))
+	assert.Equal(t, "session=abc", header.Get("Set-Cookie"))
+	assert.Nil(t, redactor.Header(nil))
+}
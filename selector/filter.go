@@ -0,0 +1,75 @@
+package selector
+
+import "strings"
+
+const excludePrefix = "!"
+
+// Filter selects the artifact source workflows: a workflow is selected if it matches any of the include selectors
+// (or there are no include selectors) and none of the exclude selectors.
+type Filter struct {
+	Include []Selector
+	Exclude []Selector
+}
+
+// ParseFilter parses the include and the exclude artifact source lists according to the given syntax.
+// Entries of the include list with a `!` prefix are exclude selectors.
+func ParseFilter(rawInclude, rawExclude, syntax string) (Filter, error) {
+	includeEntries, err := splitEntries(rawInclude, syntax)
+	if err != nil {
+		return Filter{}, err
+	}
+	excludeEntries, err := splitEntries(rawExclude, syntax)
+	if err != nil {
+		return Filter{}, err
+	}
+
+	var includes, excludes []string
+	for _, entry := range includeEntries {
+		if strings.HasPrefix(entry, excludePrefix) {
+			excludes = append(excludes, strings.TrimPrefix(entry, excludePrefix))
+		} else {
+			includes = append(includes, entry)
+		}
+	}
+	for _, entry := range excludeEntries {
+		if strings.TrimSpace(entry) != "" {
+			excludes = append(excludes, strings.TrimPrefix(entry, excludePrefix))
+		}
+	}
+
+	var filter Filter
+	if filter.Include, err = parseEntries(includes, syntax); err != nil {
+		return Filter{}, err
+	}
+	if filter.Exclude, err = parseEntries(excludes, syntax); err != nil {
+		return Filter{}, err
+	}
+
+	return filter, nil
+}
+
+// Evaluate decides whether the workflow is selected, and returns the rule which decided it.
+// The returned rule is nil if the workflow is selected because there are no include selectors,
+// or if it is not selected because none of the include selectors matched.
+func (f Filter) Evaluate(stage, workflow string) (bool, Selector) {
+	var includedBy Selector
+	if len(f.Include) > 0 {
+		for _, s := range f.Include {
+			if s.Match(stage, workflow) {
+				includedBy = s
+				break
+			}
+		}
+		if includedBy == nil {
+			return false, nil
+		}
+	}
+
+	for _, s := range f.Exclude {
+		if s.Match(stage, workflow) {
+			return false, s
+		}
+	}
+
+	return true, includedBy
+}
@@ -0,0 +1,50 @@
+package selector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFilter(t *testing.T) {
+	testCases := []struct {
+		desc             string
+		include          string
+		exclude          string
+		syntax           string
+		stage, workflow  string
+		expectedSelected bool
+		expectedRule     string
+	}{
+		{desc: "legacy include", include: "stage1\\..*", syntax: SyntaxLegacy, stage: "stage1", workflow: "wf", expectedSelected: true, expectedRule: "stage1\\..*"},
+		{desc: "legacy not included", include: "stage1\\..*", syntax: SyntaxLegacy, stage: "stage2", workflow: "wf", expectedSelected: false, expectedRule: ""},
+		{desc: "legacy exclude prefix", include: ".*,!.*nightly.*", syntax: SyntaxLegacy, stage: "stage1", workflow: "nightly-flaky", expectedSelected: false, expectedRule: ".*nightly.*"},
+		{desc: "legacy exclude input", include: ".*", exclude: ".*nightly.*", syntax: SyntaxLegacy, stage: "stage1", workflow: "nightly-flaky", expectedSelected: false, expectedRule: ".*nightly.*"},
+		{desc: "legacy entries are trimmed", include: " stage1\\..* , !.*nightly.*", syntax: SyntaxLegacy, stage: "stage1", workflow: "nightly-flaky", expectedSelected: false, expectedRule: ".*nightly.*"},
+		{desc: "legacy empty entries are dropped", include: "stage1\\..*,,", syntax: SyntaxLegacy, stage: "stage2", workflow: "wf", expectedSelected: false, expectedRule: ""},
+		{desc: "only excludes select everything else", include: "!.*nightly.*", syntax: SyntaxLegacy, stage: "stage1", workflow: "build", expectedSelected: true, expectedRule: ""},
+		{desc: "structured exclude prefix", include: "stage=build*;!workflow=*-flaky", syntax: SyntaxStructured, stage: "build", workflow: "ui-flaky", expectedSelected: false, expectedRule: "workflow=*-flaky"},
+		{desc: "structured exclude input", include: "stage=build*", exclude: "workflow=*-flaky", syntax: SyntaxStructured, stage: "build", workflow: "ui", expectedSelected: true, expectedRule: "stage=build*"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			filter, err := ParseFilter(tC.include, tC.exclude, tC.syntax)
+			assert.NoError(t, err)
+
+			selected, rule := filter.Evaluate(tC.stage, tC.workflow)
+
+			assert.Equal(t, tC.expectedSelected, selected)
+			if tC.expectedRule == "" {
+				assert.Nil(t, rule)
+			} else {
+				assert.Equal(t, tC.expectedRule, rule.String())
+			}
+		})
+	}
+}
+
+func TestParseFilter_InvalidExcludePattern(t *testing.T) {
+	_, err := ParseFilter(".*", "(", SyntaxLegacy)
+
+	assert.EqualError(t, err, "invalid artifact source pattern ((): error parsing regexp: missing closing ): `(`")
+}
@@ -0,0 +1,237 @@
+package selector
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	SyntaxLegacy     = "legacy"
+	SyntaxStructured = "structured"
+
+	// LegacyDelimiter separates the stage and the workflow name in the keys matched by the legacy syntax
+	LegacyDelimiter = "."
+)
+
+// Selector decides whether a workflow of a given stage is an artifact source
+type Selector interface {
+	Match(stage, workflow string) bool
+	String() string
+}
+
+// Parse parses the raw artifact_sources input value according to the given syntax
+func Parse(raw, syntax string) ([]Selector, error) {
+	entries, err := splitEntries(raw, syntax)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseEntries(entries, syntax)
+}
+
+// ParseLegacy creates selectors from `{stage}.{workflow}` regular expressions. The expressions are not anchored.
+func ParseLegacy(patterns []string) ([]Selector, error) {
+	var selectors []Selector
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid artifact source pattern (%s): %w", pattern, err)
+		}
+
+		selectors = append(selectors, legacySelector{pattern: re})
+	}
+
+	return selectors, nil
+}
+
+// ParseStructured creates selectors from `stage=<pattern>,workflow=<pattern>` entries separated by semicolons or new lines.
+// Patterns are globs (`*` and `?` wildcards) unless they are wrapped in slashes (`/regex/`), in which case they are
+// anchored regular expressions. A `!` prefix negates a pattern. A missing stage or workflow pattern matches anything.
+func ParseStructured(raw string) ([]Selector, error) {
+	return Parse(raw, SyntaxStructured)
+}
+
+func splitEntries(raw, syntax string) ([]string, error) {
+	var separators func(r rune) bool
+	switch syntax {
+	case "", SyntaxLegacy:
+		separators = func(r rune) bool { return r == ',' }
+	case SyntaxStructured:
+		separators = func(r rune) bool { return r == ';' || r == '\n' }
+	default:
+		return nil, fmt.Errorf("unknown artifact source syntax: %s", syntax)
+	}
+
+	var entries []string
+	for _, entry := range strings.FieldsFunc(raw, separators) {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func parseEntries(entries []string, syntax string) ([]Selector, error) {
+	if syntax == SyntaxStructured {
+		var selectors []Selector
+		for _, entry := range entries {
+			s, err := parseStructuredEntry(entry)
+			if err != nil {
+				return nil, err
+			}
+			selectors = append(selectors, s)
+		}
+		return selectors, nil
+	}
+
+	return ParseLegacy(entries)
+}
+
+type legacySelector struct {
+	pattern *regexp.Regexp
+}
+
+func (s legacySelector) Match(stage, workflow string) bool {
+	return s.pattern.MatchString(stage + LegacyDelimiter + workflow)
+}
+
+func (s legacySelector) String() string {
+	return s.pattern.String()
+}
+
+type structuredSelector struct {
+	raw      string
+	stage    *namePattern
+	workflow *namePattern
+}
+
+func (s structuredSelector) Match(stage, workflow string) bool {
+	if s.stage != nil && !s.stage.match(stage) {
+		return false
+	}
+	if s.workflow != nil && !s.workflow.match(workflow) {
+		return false
+	}
+
+	return true
+}
+
+func (s structuredSelector) String() string {
+	return s.raw
+}
+
+func parseStructuredEntry(entry string) (structuredSelector, error) {
+	s := structuredSelector{raw: entry}
+
+	for _, term := range splitTerms(entry) {
+		split := strings.SplitN(term, "=", 2)
+		if len(split) != 2 {
+			return structuredSelector{}, fmt.Errorf("invalid artifact source selector (%s): %s is not a key=pattern term", entry, term)
+		}
+
+		key, value := strings.TrimSpace(split[0]), strings.TrimSpace(split[1])
+		pattern, err := parseNamePattern(value)
+		if err != nil {
+			return structuredSelector{}, fmt.Errorf("invalid artifact source selector (%s): %w", entry, err)
+		}
+
+		switch key {
+		case "stage":
+			if s.stage != nil {
+				return structuredSelector{}, fmt.Errorf("invalid artifact source selector (%s): stage is defined multiple times", entry)
+			}
+			s.stage = &pattern
+		case "workflow":
+			if s.workflow != nil {
+				return structuredSelector{}, fmt.Errorf("invalid artifact source selector (%s): workflow is defined multiple times", entry)
+			}
+			s.workflow = &pattern
+		default:
+			return structuredSelector{}, fmt.Errorf("invalid artifact source selector (%s): unknown key: %s", entry, key)
+		}
+	}
+
+	return s, nil
+}
+
+// splitTerms splits the entry at the commas, spaces and tabs, except inside /regex/ values (like workflow=/a{1,3}/)
+func splitTerms(entry string) []string {
+	var (
+		terms   []string
+		term    strings.Builder
+		inRegex bool
+	)
+	flush := func() {
+		if term.Len() > 0 {
+			terms = append(terms, term.String())
+			term.Reset()
+		}
+	}
+
+	for _, r := range entry {
+		switch {
+		case r == '/' && inRegex && !strings.HasSuffix(term.String(), "\\"):
+			inRegex = false
+		case r == '/' && !inRegex && (strings.HasSuffix(term.String(), "=") || strings.HasSuffix(term.String(), "=!")):
+			inRegex = true
+		case !inRegex && (r == ',' || r == ' ' || r == '\t'):
+			flush()
+			continue
+		}
+		term.WriteRune(r)
+	}
+	flush()
+
+	return terms
+}
+
+type namePattern struct {
+	negated bool
+	pattern *regexp.Regexp
+}
+
+func (p namePattern) match(name string) bool {
+	return p.pattern.MatchString(name) != p.negated
+}
+
+func parseNamePattern(value string) (namePattern, error) {
+	var p namePattern
+	if strings.HasPrefix(value, "!") {
+		p.negated = true
+		value = value[1:]
+	}
+
+	var expression string
+	if len(value) >= 2 && strings.HasPrefix(value, "/") && strings.HasSuffix(value, "/") {
+		expression = "^(?:" + value[1:len(value)-1] + ")$"
+	} else {
+		expression = globToRegexp(value)
+	}
+
+	re, err := regexp.Compile(expression)
+	if err != nil {
+		return namePattern{}, fmt.Errorf("invalid pattern (%s): %w", value, err)
+	}
+	p.pattern = re
+
+	return p, nil
+}
+
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+
+	return b.String()
+}
@@ -0,0 +1,81 @@
+package selector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLegacy_MatchesUnanchoredStageWorkflowKey(t *testing.T) {
+	selectors, err := ParseLegacy([]string{"stage1.wf"})
+	assert.NoError(t, err)
+
+	assert.True(t, selectors[0].Match("stage1", "wf"))
+	assert.True(t, selectors[0].Match("stage1", "wf-old"))
+	assert.False(t, selectors[0].Match("stage2", "wf"))
+}
+
+func TestParseLegacy_InvalidPattern(t *testing.T) {
+	_, err := ParseLegacy([]string{"stage1.(wf"})
+
+	assert.EqualError(t, err, "invalid artifact source pattern (stage1.(wf): error parsing regexp: missing closing ): `stage1.(wf`")
+}
+
+func TestParseStructured(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		raw      string
+		stage    string
+		workflow string
+		expected bool
+	}{
+		{desc: "exact names match", raw: "stage=stage1,workflow=wf", stage: "stage1", workflow: "wf", expected: true},
+		{desc: "exact names are anchored", raw: "stage=stage1,workflow=wf", stage: "stage10", workflow: "wf-old", expected: false},
+		{desc: "dots in names are literal", raw: "stage=release.1 workflow=build", stage: "release-1", workflow: "build", expected: false},
+		{desc: "dots in names match", raw: "stage=release.1 workflow=build", stage: "release.1", workflow: "build", expected: true},
+		{desc: "glob wildcard", raw: "stage=deploy*", stage: "deploy-prod", workflow: "any", expected: true},
+		{desc: "glob single character wildcard", raw: "workflow=test-?", stage: "any", workflow: "test-12", expected: false},
+		{desc: "negated glob", raw: "stage=deploy*,workflow=!*-flaky", stage: "deploy", workflow: "ui-flaky", expected: false},
+		{desc: "negated glob does not match", raw: "stage=deploy*,workflow=!*-flaky", stage: "deploy", workflow: "ui", expected: true},
+		{desc: "anchored regex", raw: "workflow=/test-[0-9]+/", stage: "any", workflow: "test-12", expected: true},
+		{desc: "regex with comma", raw: "workflow=/a{1,3}/ stage=build", stage: "build", workflow: "aa", expected: true},
+		{desc: "regex with comma does not match", raw: "workflow=/a{1,3}/ stage=build", stage: "build", workflow: "aaaa", expected: false},
+		{desc: "negated regex with space", raw: "workflow=!/ui test/,stage=build", stage: "build", workflow: "ui test", expected: false},
+		{desc: "anchored regex does not match substring", raw: "workflow=/test-[0-9]+/", stage: "any", workflow: "ui-test-12", expected: false},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			selectors, err := ParseStructured(tC.raw)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tC.expected, selectors[0].Match(tC.stage, tC.workflow))
+			assert.Equal(t, tC.raw, selectors[0].String())
+		})
+	}
+}
+
+func TestParseStructured_MultipleEntries(t *testing.T) {
+	selectors, err := ParseStructured("stage=build;\nworkflow=deploy\n")
+
+	assert.NoError(t, err)
+	assert.Len(t, selectors, 2)
+}
+
+func TestParseStructured_Errors(t *testing.T) {
+	testCases := []struct {
+		raw           string
+		expectedError string
+	}{
+		{raw: "stage", expectedError: "invalid artifact source selector (stage): stage is not a key=pattern term"},
+		{raw: "branch=main", expectedError: "invalid artifact source selector (branch=main): unknown key: branch"},
+		{raw: "stage=a,stage=b", expectedError: "invalid artifact source selector (stage=a,stage=b): stage is defined multiple times"},
+		{raw: "workflow=/(/", expectedError: "invalid artifact source selector (workflow=/(/): invalid pattern (/(/): error parsing regexp: missing closing ): `^(?:()$`"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.raw, func(t *testing.T) {
+			_, err := ParseStructured(tC.raw)
+
+			assert.EqualError(t, err, tC.expectedError)
+		})
+	}
+}
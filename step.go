@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"strings"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/bitrise-steplib/bitrise-step-artifact-pull/export"
@@ -14,33 +19,122 @@ import (
 	"github.com/bitrise-io/go-utils/log"
 	"github.com/bitrise-io/go-utils/pathutil"
 	"github.com/bitrise-steplib/bitrise-step-artifact-pull/api"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/apirecord"
 	"github.com/bitrise-steplib/bitrise-step-artifact-pull/downloader"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/hook"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/humanize"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/junit"
 	"github.com/bitrise-steplib/bitrise-step-artifact-pull/model"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/redact"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/selector"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/summary"
 )
 
-const downloadDirPrefix = "_artifact_pull"
+const (
+	downloadDirPrefix = "_artifact_pull"
+	exportDirPrefix   = "_artifact_pull_exports"
+
+	mergedJUnitFileName = "merged-junit.xml"
+
+	buildSelectionModePipeline              = "pipeline"
+	buildSelectionModeLatestSuccessfulBuild = "latest_successful_build"
+
+	defaultDownloadThreads             = 10
+	defaultDownloadTimeout             = 5 * time.Minute
+	defaultAPIListWorkers              = 3
+	defaultAPIShowWorkers              = 3
+	defaultAPITimeout                  = 30 * time.Second
+	defaultPostDownloadHookParallelism = 4
+)
 
 type Input struct {
-	Verbose               string          `env:"verbose,opt[true,false]"`
-	ArtifactSources       string          `env:"artifact_sources"`
-	ExportMap             string          `env:"export_map"`
-	FinishedStages        string          `env:"finished_stage"`
-	BitriseAPIAccessToken stepconf.Secret `env:"bitrise_api_access_token"`
-	BitriseAPIBaseURL     string          `env:"bitrise_api_base_url"`
+	Verbose                     string          `env:"verbose,opt[true,false]"`
+	ArtifactSources             string          `env:"artifact_sources"`
+	ArtifactSourcesSyntax       string          `env:"artifact_sources_syntax,opt[legacy,structured]"`
+	ArtifactSourcesExclude      string          `env:"artifact_sources_exclude"`
+	FailOnUnmatchedSources      string          `env:"fail_on_unmatched_sources,opt[true,false]"`
+	LatestWorkflowRunOnly       string          `env:"latest_workflow_run_only,opt[true,false]"`
+	FinishedWithin              string          `env:"finished_within"`
+	ExportMap                   string          `env:"export_map"`
+	ExportDestinationMode       string          `env:"export_destination_mode,opt[copy,hardlink,symlink]"`
+	ExportEncoding              string          `env:"export_encoding,opt[pipe,newline,json,file]"`
+	ExportGroupedPaths          string          `env:"export_grouped_paths,opt[true,false]"`
+	PostDownloadHook            string          `env:"post_download_hook"`
+	PostDownloadHookParallelism string          `env:"post_download_hook_parallelism"`
+	DeployArtifacts             string          `env:"deploy_artifacts"`
+	DeployDir                   string          `env:"deploy_dir"`
+	TestResults                 string          `env:"test_results"`
+	TestResultDir               string          `env:"test_result_dir"`
+	JUnitMerge                  string          `env:"junit_merge"`
+	APIRecordBundle             string          `env:"api_record_bundle"`
+	APIReplayBundle             string          `env:"api_replay_bundle"`
+	SummaryJSONPath             string          `env:"summary_json_path"`
+	DownloadMaxConnsPerHost     string          `env:"download_max_connections_per_host"`
+	DownloadHTTP2               string          `env:"download_http2,opt[true,false]"`
+	DownloadAttemptTimeout      string          `env:"download_attempt_timeout"`
+	DownloadRetryMax            string          `env:"download_retry_max"`
+	DownloadRetryWaitMax        string          `env:"download_retry_wait_max"`
+	DownloadProxyURL            stepconf.Secret `env:"download_proxy_url"`
+	DownloadCABundle            string          `env:"download_ca_bundle"`
+	DownloadThreads             string          `env:"download_threads"`
+	DownloadTimeout             string          `env:"download_timeout"`
+	MaxDownloadRate             string          `env:"max_download_rate"`
+	APIListWorkers              string          `env:"api_list_workers"`
+	APIShowWorkers              string          `env:"api_show_workers"`
+	APITimeout                  string          `env:"api_timeout"`
+	StepTimeout                 string          `env:"step_timeout"`
+	FinishedStages              string          `env:"finished_stage"`
+	BitriseAPIAccessToken       stepconf.Secret `env:"bitrise_api_access_token"`
+	BitriseAPIBaseURL           string          `env:"bitrise_api_base_url"`
+	BuildSelectionMode          string          `env:"build_selection_mode,opt[pipeline,latest_successful_build]"`
+	LatestBuildBranch           string          `env:"latest_build_branch"`
+	LatestBuildWorkflow         string          `env:"latest_build_workflow"`
 }
 
 type Config struct {
-	VerboseLogging        bool
-	ArtifactSources       []string
-	ExportMap             map[string]string
-	FinishedStages        model.FinishedStages
-	BitriseAPIAccessToken string
-	BitriseAPIBaseURL     string
-	AppSlug               string
+	VerboseLogging              bool
+	SourceFilter                selector.Filter
+	FailOnUnmatchedSources      bool
+	LatestWorkflowRunOnly       bool
+	FinishedWithin              time.Duration
+	ExportMap                   []export.Rule
+	ExportDestinationMode       string
+	ExportEncoding              string
+	ExportGroupedPaths          bool
+	PostDownloadHook            string
+	PostDownloadHookParallelism int
+	DeploySelection             []export.Rule
+	DeployDir                   string
+	TestResultSelection         []export.Rule
+	TestResultDir               string
+	JUnitMergeSelection         []export.Rule
+	APIRecordBundle             string
+	APIReplayBundle             string
+	SummaryJSONPath             string
+	DownloadClient              downloader.ClientConfig
+	DownloadThreads             int
+	DownloadTimeout             time.Duration
+	MaxDownloadBytesPerSecond   int64
+	APIListWorkers              int
+	APIShowWorkers              int
+	APITimeout                  time.Duration
+	StepTimeout                 time.Duration
+	FinishedStages              model.FinishedStages
+	BitriseAPIAccessToken       string
+	BitriseAPIBaseURL           string
+	AppSlug                     string
+	BuildSelectionMode          string
+	LatestBuildBranch           string
+	LatestBuildWorkflow         string
 }
 
 type Result struct {
-	ArtifactLocations []string
+	Artifacts       []model.PulledArtifact
+	SourceBuildSlug string
+	// Report is completed with the export phase and printed by Export
+	Report *summary.Report
+	// Redactor is created by Run, Export masks its log messages and error with the same redactor
+	Redactor *redact.Redactor
 }
 
 type ArtifactPull struct {
@@ -67,6 +161,91 @@ func (a ArtifactPull) ProcessConfig() (Config, error) {
 		}
 	}
 
+	sourceFilter, err := selector.ParseFilter(input.ArtifactSources, input.ArtifactSourcesExclude, input.ArtifactSourcesSyntax)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse step inputs: %w", err)
+	}
+
+	exportMap, err := export.ParseExportMap(input.ExportMap, a.logger)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse step inputs: %w", err)
+	}
+
+	deploySelection, err := export.ParseSelection("deploy_artifacts", input.DeployArtifacts)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse step inputs: %w", err)
+	}
+	if len(deploySelection) > 0 && input.DeployDir == "" {
+		return Config{}, fmt.Errorf("failed to parse step inputs: deploy_dir should be set if deploy_artifacts is defined")
+	}
+
+	testResultSelection, err := export.ParseSelection("test_results", input.TestResults)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse step inputs: %w", err)
+	}
+	if len(testResultSelection) > 0 && input.TestResultDir == "" {
+		return Config{}, fmt.Errorf("failed to parse step inputs: test_result_dir should be set if test_results is defined")
+	}
+
+	junitMergeSelection, err := export.ParseSelection("junit_merge", input.JUnitMerge)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse step inputs: %w", err)
+	}
+
+	if input.APIRecordBundle != "" && input.APIReplayBundle != "" {
+		return Config{}, fmt.Errorf("failed to parse step inputs: api_record_bundle and api_replay_bundle can not be set at the same time")
+	}
+
+	var finishedWithin time.Duration
+	if input.FinishedWithin != "" {
+		finishedWithin, err = time.ParseDuration(input.FinishedWithin)
+		if err != nil || finishedWithin <= 0 {
+			return Config{}, fmt.Errorf("failed to parse step inputs: finished_within should be a positive duration, like 2h or 30m, got: %s", input.FinishedWithin)
+		}
+	}
+
+	postDownloadHookParallelism, err := parsePositiveInt("post_download_hook_parallelism", input.PostDownloadHookParallelism, defaultPostDownloadHookParallelism)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse step inputs: %w", err)
+	}
+
+	downloadThreads, err := parsePositiveInt("download_threads", input.DownloadThreads, defaultDownloadThreads)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse step inputs: %w", err)
+	}
+	downloadTimeout, err := parsePositiveDuration("download_timeout", input.DownloadTimeout, defaultDownloadTimeout)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse step inputs: %w", err)
+	}
+	var maxDownloadBytesPerSecond int64
+	if input.MaxDownloadRate != "" {
+		if maxDownloadBytesPerSecond, err = humanize.ParseRate(input.MaxDownloadRate); err != nil {
+			return Config{}, fmt.Errorf("failed to parse step inputs: max_download_rate should be a transfer rate, like 50MB/s, got: %s", input.MaxDownloadRate)
+		}
+	}
+	apiListWorkers, err := parsePositiveInt("api_list_workers", input.APIListWorkers, defaultAPIListWorkers)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse step inputs: %w", err)
+	}
+	apiShowWorkers, err := parsePositiveInt("api_show_workers", input.APIShowWorkers, defaultAPIShowWorkers)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse step inputs: %w", err)
+	}
+	apiTimeout, err := parsePositiveDuration("api_timeout", input.APITimeout, defaultAPITimeout)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse step inputs: %w", err)
+	}
+	// no step deadline by default
+	stepTimeout, err := parsePositiveDuration("step_timeout", input.StepTimeout, 0)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse step inputs: %w", err)
+	}
+
+	downloadClient, err := parseDownloadClientConfig(input, downloadThreads)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse step inputs: %w", err)
+	}
+
 	appSlug := a.envRepository.Get("BITRISE_APP_SLUG")
 	if appSlug == "" {
 		return Config{}, fmt.Errorf("app slug (BITRISE_APP_SLUG env var) not found")
@@ -78,34 +257,244 @@ func (a ArtifactPull) ProcessConfig() (Config, error) {
 	}
 
 	return Config{
-		VerboseLogging:        verboseLoggingValue,
-		ArtifactSources:       strings.Split(input.ArtifactSources, ","),
-		ExportMap:             export.ProcessRawExportMap(input.ExportMap),
-		FinishedStages:        finishedStagesModel,
-		BitriseAPIAccessToken: string(input.BitriseAPIAccessToken),
-		BitriseAPIBaseURL:     input.BitriseAPIBaseURL,
-		AppSlug:               appSlug,
+		VerboseLogging:              verboseLoggingValue,
+		SourceFilter:                sourceFilter,
+		FailOnUnmatchedSources:      input.FailOnUnmatchedSources == "true",
+		LatestWorkflowRunOnly:       input.LatestWorkflowRunOnly == "true",
+		FinishedWithin:              finishedWithin,
+		ExportMap:                   exportMap,
+		ExportDestinationMode:       input.ExportDestinationMode,
+		ExportEncoding:              input.ExportEncoding,
+		ExportGroupedPaths:          input.ExportGroupedPaths == "true",
+		PostDownloadHook:            input.PostDownloadHook,
+		PostDownloadHookParallelism: postDownloadHookParallelism,
+		DeploySelection:             deploySelection,
+		DeployDir:                   input.DeployDir,
+		TestResultSelection:         testResultSelection,
+		TestResultDir:               input.TestResultDir,
+		JUnitMergeSelection:         junitMergeSelection,
+		APIRecordBundle:             input.APIRecordBundle,
+		APIReplayBundle:             input.APIReplayBundle,
+		SummaryJSONPath:             input.SummaryJSONPath,
+		DownloadClient:              downloadClient,
+		DownloadThreads:             downloadThreads,
+		DownloadTimeout:             downloadTimeout,
+		MaxDownloadBytesPerSecond:   maxDownloadBytesPerSecond,
+		APIListWorkers:              apiListWorkers,
+		APIShowWorkers:              apiShowWorkers,
+		APITimeout:                  apiTimeout,
+		StepTimeout:                 stepTimeout,
+		FinishedStages:              finishedStagesModel,
+		BitriseAPIAccessToken:       string(input.BitriseAPIAccessToken),
+		BitriseAPIBaseURL:           input.BitriseAPIBaseURL,
+		AppSlug:                     appSlug,
+		BuildSelectionMode:          input.BuildSelectionMode,
+		LatestBuildBranch:           input.LatestBuildBranch,
+		LatestBuildWorkflow:         input.LatestBuildWorkflow,
 	}, nil
 }
 
+// parsePositiveInt parses an optional positive integer input, the default value is returned if the input is empty
+func parsePositiveInt(name, value string, defaultValue int) (int, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 1 {
+		return 0, fmt.Errorf("%s should be a positive integer, got: %s", name, value)
+	}
+
+	return parsed, nil
+}
+
+// parsePositiveDuration parses an optional positive duration input, the default value is returned if the input is empty
+func parsePositiveDuration(name, value string, defaultValue time.Duration) (time.Duration, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed <= 0 {
+		return 0, fmt.Errorf("%s should be a positive duration, like 1m or 30s, got: %s", name, value)
+	}
+
+	return parsed, nil
+}
+
+// parseDownloadClientConfig returns the download client settings, the defaults are kept for the empty inputs. The
+// connections per host default to the number of download threads, so that every thread can have its own connection.
+func parseDownloadClientConfig(input Input, downloadThreads int) (downloader.ClientConfig, error) {
+	cfg := downloader.DefaultClientConfig()
+
+	var err error
+	if cfg.MaxConnsPerHost, err = parsePositiveInt("download_max_connections_per_host", input.DownloadMaxConnsPerHost, downloadThreads); err != nil {
+		return downloader.ClientConfig{}, err
+	}
+
+	if input.DownloadHTTP2 != "" {
+		cfg.HTTP2 = input.DownloadHTTP2 == "true"
+	}
+
+	if cfg.AttemptTimeout, err = parsePositiveDuration("download_attempt_timeout", input.DownloadAttemptTimeout, cfg.AttemptTimeout); err != nil {
+		return downloader.ClientConfig{}, err
+	}
+
+	if input.DownloadRetryMax != "" {
+		retryMax, err := strconv.Atoi(input.DownloadRetryMax)
+		if err != nil || retryMax < 0 {
+			return downloader.ClientConfig{}, fmt.Errorf("download_retry_max should be a non-negative integer, got: %s", input.DownloadRetryMax)
+		}
+		cfg.RetryMax = retryMax
+	}
+
+	if cfg.RetryWaitMax, err = parsePositiveDuration("download_retry_wait_max", input.DownloadRetryWaitMax, cfg.RetryWaitMax); err != nil {
+		return downloader.ClientConfig{}, err
+	}
+
+	if proxyURL := string(input.DownloadProxyURL); proxyURL != "" {
+		// the URL can hold the credentials of the proxy, so it is not included in the error
+		if u, err := url.Parse(proxyURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return downloader.ClientConfig{}, fmt.Errorf("download_proxy_url should be an absolute URL, like http://proxy.example.com:3128")
+		}
+		cfg.ProxyURL = proxyURL
+	}
+
+	if input.DownloadCABundle != "" {
+		if exists, err := pathutil.IsPathExists(input.DownloadCABundle); err != nil || !exists {
+			return downloader.ClientConfig{}, fmt.Errorf("download_ca_bundle file does not exist: %s", input.DownloadCABundle)
+		}
+		cfg.CABundlePath = input.DownloadCABundle
+	}
+
+	return cfg, nil
+}
+
+// Run pulls the artifacts, the log messages and the returned error are redacted (see redact.Redactor)
 func (a ArtifactPull) Run(cfg Config) (Result, error) {
+	redactor := redact.New(cfg.BitriseAPIAccessToken)
+	a.logger = redactor.Logger(a.logger)
+
+	ctx := context.Background()
+	if cfg.StepTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.StepTimeout)
+		defer cancel()
+	}
+
+	report := summary.NewReport()
+	result, err := a.run(ctx, cfg, report, redactor)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("the step did not finish within the step_timeout (%s): %w", cfg.StepTimeout, err)
+		}
+		a.reportSummary(report, cfg)
+		return Result{}, redactor.Error(err)
+	}
+
+	result.Report = report
+	result.Redactor = &redactor
+
+	return result, nil
+}
+
+func (a ArtifactPull) run(ctx context.Context, cfg Config, report *summary.Report, redactor redact.Redactor) (Result, error) {
 	a.logger.EnableDebugLog(cfg.VerboseLogging)
-	buildIdGetter := NewBuildIDGetter(cfg.FinishedStages, cfg.ArtifactSources)
-	buildIDs, err := buildIdGetter.GetBuildIDs()
+
+	tunedTransport, err := downloader.NewTransport(cfg.DownloadClient)
 	if err != nil {
-		return Result{}, err
+		return Result{}, fmt.Errorf("failed to create the download client: %w", err)
+	}
+
+	apiOptions := []api.ClientOption{api.WithContext(ctx), api.WithTimeout(cfg.APITimeout)}
+	var downloadTransport http.RoundTripper = tunedTransport
+	switch {
+	case cfg.APIReplayBundle != "":
+		bundle, err := apirecord.LoadBundle(cfg.APIReplayBundle)
+		if err != nil {
+			return Result{}, err
+		}
+		if len(cfg.FinishedStages) == 0 && len(bundle.FinishedStages) > 0 {
+			if err := json.Unmarshal(bundle.FinishedStages, &cfg.FinishedStages); err != nil {
+				return Result{}, fmt.Errorf("failed to parse the finished stages of the API record bundle: %w", err)
+			}
+		}
+
+		a.logger.Warnf("Replaying the API interactions recorded in %s", cfg.APIReplayBundle)
+
+		downloadTransport = bundle.ReplayTransport()
+		apiOptions = append(apiOptions, api.WithTransport(downloadTransport))
+	case cfg.APIRecordBundle != "":
+		finishedStages, err := json.Marshal(cfg.FinishedStages)
+		if err != nil {
+			return Result{}, err
+		}
+		bundle := apirecord.NewBundle()
+		bundle.FinishedStages = finishedStages
+		// the bundle is saved even if the step fails, to be able to reproduce the failure
+		defer func() {
+			if saveErr := bundle.Save(cfg.APIRecordBundle); saveErr != nil {
+				a.logger.Warnf("Failed to save the API record bundle: %s", saveErr)
+				return
+			}
+			a.logger.Printf("API interactions recorded to %s", cfg.APIRecordBundle)
+		}()
+
+		apiOptions = append(apiOptions, api.WithTransport(bundle.RecordingTransport(nil, redactor, "")))
+		// the downloaded content is streamed unmodified next to the bundle, so that the replay reproduces the run byte
+		// for byte without holding the artifacts in memory
+		downloadTransport = bundle.RecordingTransport(downloadTransport, redactor, apirecord.BodiesDir(cfg.APIRecordBundle))
+	}
+
+	var (
+		buildIDs        []string
+		sourceBuildSlug string
+		sourceWorkflow  string
+	)
+	stopBuildSelection := report.StartPhase("build selection")
+	if cfg.BuildSelectionMode == buildSelectionModeLatestSuccessfulBuild {
+		buildFinder, err := api.NewLatestBuildFinder(cfg.BitriseAPIBaseURL, cfg.BitriseAPIAccessToken, a.logger, apiOptions...)
+		if err != nil {
+			return Result{}, err
+		}
+		build, err := buildFinder.FindLatestSuccessfulBuild(cfg.AppSlug, cfg.LatestBuildBranch, cfg.LatestBuildWorkflow)
+		if err != nil {
+			return Result{}, err
+		}
+
+		a.logger.Printf("Pulling artifacts of build #%d (workflow: %s, branch: %s): https://app.bitrise.io/build/%s", build.BuildNumber, build.TriggeredWorkflow, build.Branch, build.Slug)
+
+		sourceBuildSlug = build.Slug
+		sourceWorkflow = build.TriggeredWorkflow
+		buildIDs = []string{build.Slug}
+	} else {
+		buildIdGetter := NewBuildIDGetter(cfg.FinishedStages, cfg.SourceFilter, cfg.FailOnUnmatchedSources, a.logger)
+		buildIdGetter.LatestRunOnly = cfg.LatestWorkflowRunOnly
+		if cfg.FinishedWithin > 0 {
+			buildIdGetter.FinishedAfter = time.Now().Add(-cfg.FinishedWithin)
+		}
+		var err error
+		buildIDs, err = buildIdGetter.GetBuildIDs()
+		if err != nil {
+			return Result{}, err
+		}
 	}
 
+	stopBuildSelection()
+
 	a.logger.Debugf("Downloading artifacts for builds %+v", buildIDs)
 
 	a.logger.Printf("Getting the list of artifacts of %d builds", len(buildIDs))
 
-	artifactLister, err := api.NewArtifactLister(cfg.BitriseAPIBaseURL, cfg.BitriseAPIAccessToken, a.logger)
+	artifactLister, err := api.NewArtifactLister(cfg.BitriseAPIBaseURL, cfg.BitriseAPIAccessToken, a.logger, apiOptions...)
 	if err != nil {
 		a.logger.Debugf("Failed to create artifact lister", err)
 		return Result{}, err
 	}
+	artifactLister = artifactLister.WithConcurrency(cfg.APIListWorkers, cfg.APIShowWorkers)
+	stopListing := report.StartPhase("listing")
 	artifacts, err := artifactLister.ListBuildArtifactDetails(cfg.AppSlug, buildIDs)
+	stopListing()
 	if err != nil {
 		a.logger.Debugf("Failed to list artifacts", err)
 		return Result{}, err
@@ -119,41 +508,221 @@ func (a ArtifactPull) Run(cfg Config) (Result, error) {
 		return Result{}, err
 	}
 
-	artifactDownloader := downloader.NewConcurrentArtifactDownloader(artifacts, 5*time.Minute, targetDir, a.logger)
+	artifactDownloader := downloader.NewConcurrentArtifactDownloader(artifacts, cfg.DownloadTimeout, targetDir, a.logger)
+	artifactDownloader.Threads = cfg.DownloadThreads
+	artifactDownloader.Context = ctx
+	artifactDownloader.MaxBytesPerSecond = cfg.MaxDownloadBytesPerSecond
+	if cfg.MaxDownloadBytesPerSecond > 0 {
+		a.logger.Printf("The download bandwidth is limited to %s/s", humanize.Bytes(cfg.MaxDownloadBytesPerSecond))
+	}
+	artifactDownloader.HTTPClient = downloader.NewHTTPClient(cfg.DownloadClient, downloadTransport, a.logger)
 
+	stopDownload := report.StartPhase("download")
 	downloadResults, err := artifactDownloader.DownloadAndSaveArtifacts()
+	stopDownload()
 	if err != nil {
 		a.logger.Printf("Failed", err)
 		return Result{}, err
 	}
 
-	var downloadedArtifactPaths []string
+	var (
+		pulledArtifacts []model.PulledArtifact
+		downloadErr     error
+	)
 	for _, downloadResult := range downloadResults {
+		pulledArtifact := model.PulledArtifact{
+			Path:      downloadResult.DownloadPath,
+			Title:     downloadResult.Title,
+			BuildSlug: downloadResult.BuildSlug,
+			Workflow:  sourceWorkflow,
+		}
+		if stage, wf, ok := cfg.FinishedStages.FindBuild(downloadResult.BuildSlug); ok {
+			pulledArtifact.Stage = stage
+			pulledArtifact.Workflow = wf.Name
+		}
+
+		summaryArtifact := summary.Artifact{
+			Stage:     pulledArtifact.Stage,
+			Workflow:  pulledArtifact.Workflow,
+			BuildSlug: downloadResult.BuildSlug,
+			Title:     downloadResult.Title,
+			Size:      downloadResult.Size,
+			Duration:  downloadResult.Duration,
+			Status:    summary.StatusDownloaded,
+		}
+
 		if downloadResult.DownloadError != nil {
 			a.logger.Errorf("Failed to download artifact from %s, error: %s", downloadResult.DownloadURL, downloadResult.DownloadError.Error())
 
-			return Result{}, downloadResult.DownloadError
-		} else {
-			if cfg.VerboseLogging {
-				a.logger.Printf("Artifact downloaded: %s", downloadResult.DownloadPath)
+			summaryArtifact.Status = summary.StatusFailed
+			summaryArtifact.Error = downloadResult.DownloadError.Error()
+			report.AddArtifact(summaryArtifact)
+			if downloadErr == nil {
+				downloadErr = downloadResult.DownloadError
 			}
+			continue
+		}
+
+		if cfg.VerboseLogging {
+			a.logger.Printf("Artifact downloaded: %s", downloadResult.DownloadPath)
+		}
+
+		report.AddArtifact(summaryArtifact)
+		pulledArtifacts = append(pulledArtifacts, pulledArtifact)
+	}
+	if downloadErr != nil {
+		return Result{}, downloadErr
+	}
+
+	model.SortPulledArtifacts(pulledArtifacts, cfg.FinishedStages)
+
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+
+	if cfg.PostDownloadHook != "" && len(pulledArtifacts) > 0 {
+		a.logger.Println()
+		a.logger.Printf("Running the post-download hook for %d artifacts", len(pulledArtifacts))
 
-			downloadedArtifactPaths = append(downloadedArtifactPaths, downloadResult.DownloadPath)
+		hookRunner := hook.NewRunner(cfg.PostDownloadHook, cfg.PostDownloadHookParallelism, a.cmdFactory, a.logger)
+		hookRunner.Context = ctx
+		stopHook := report.StartPhase("post-download hook")
+		err := hookRunner.Run(pulledArtifacts)
+		stopHook()
+		if err != nil {
+			return Result{}, err
 		}
 	}
 
-	return Result{ArtifactLocations: downloadedArtifactPaths}, nil
+	return Result{Artifacts: pulledArtifacts, SourceBuildSlug: sourceBuildSlug}, nil
+}
+
+// Export exports the pulled artifacts, the log messages and the returned error are redacted (see redact.Redactor)
+func (a ArtifactPull) Export(result Result, cfg Config) error {
+	redactor := result.Redactor
+	if redactor == nil {
+		r := redact.New(cfg.BitriseAPIAccessToken)
+		redactor = &r
+	}
+	a.logger = redactor.Logger(a.logger)
+
+	report := result.Report
+	if report == nil {
+		report = summary.NewReport()
+	}
+
+	stopExport := report.StartPhase("export")
+	err := a.export(result, cfg)
+	stopExport()
+
+	a.reportSummary(report, cfg)
+
+	return redactor.Error(err)
 }
 
-func (a ArtifactPull) Export(result Result, exportMap map[string]string) error {
+// reportSummary prints the summary of the run, and writes it into the summary JSON file if it is set
+func (a ArtifactPull) reportSummary(report *summary.Report, cfg Config) {
+	report.Print(a.logger)
+
+	if cfg.SummaryJSONPath == "" {
+		return
+	}
+	if err := report.WriteJSON(cfg.SummaryJSONPath); err != nil {
+		a.logger.Warnf("Failed to write the summary JSON: %s", err)
+		return
+	}
+	a.logger.Printf("Summary written to %s", cfg.SummaryJSONPath)
+}
+
+func (a ArtifactPull) export(result Result, cfg Config) error {
+	if result.SourceBuildSlug != "" {
+		if err := a.envRepository.Set("BITRISE_ARTIFACT_SOURCE_BUILD_SLUG", result.SourceBuildSlug); err != nil {
+			return fmt.Errorf("failed to export source build slug, error: %s", err)
+		}
+		a.logger.Printf("$BITRISE_ARTIFACT_SOURCE_BUILD_SLUG = %s", result.SourceBuildSlug)
+	}
+
+	exportDir, err := dirNamePrefix(exportDirPrefix)
+	if err != nil {
+		return err
+	}
+
 	exporter := export.OutputExporter{
-		ExportPattern: exportMap,
-		ExportValues:  strings.Join(result.ArtifactLocations, "|"),
-		Logger:        a.logger,
-		EnvRepository: a.envRepository,
+		Rules:           cfg.ExportMap,
+		Artifacts:       result.Artifacts,
+		DestinationMode: cfg.ExportDestinationMode,
+		Encoding:        cfg.ExportEncoding,
+		FileDir:         exportDir,
+		GroupedPaths:    cfg.ExportGroupedPaths,
+		Logger:          a.logger,
+		EnvRepository:   a.envRepository,
+	}
+
+	if err := exporter.Export(); err != nil {
+		return err
+	}
+
+	if len(cfg.DeploySelection) > 0 {
+		a.logger.Println()
+		a.logger.Printf("Placing the selected artifacts to the deploy directory: %s", cfg.DeployDir)
+
+		deployDirExporter := export.DeployDirExporter{
+			Selection: cfg.DeploySelection,
+			Artifacts: result.Artifacts,
+			DeployDir: cfg.DeployDir,
+			Logger:    a.logger,
+		}
+		if _, err := deployDirExporter.Deploy(); err != nil {
+			return err
+		}
+	}
+
+	if len(cfg.TestResultSelection) > 0 {
+		a.logger.Println()
+		a.logger.Printf("Placing the selected test results to the test result directory: %s", cfg.TestResultDir)
+
+		testResultExporter := export.TestResultExporter{
+			Selection:     cfg.TestResultSelection,
+			Artifacts:     result.Artifacts,
+			TestResultDir: cfg.TestResultDir,
+			Logger:        a.logger,
+		}
+		if _, err := testResultExporter.Export(); err != nil {
+			return err
+		}
+	}
+
+	if len(cfg.JUnitMergeSelection) > 0 {
+		if err := a.mergeJUnitReports(result, cfg, exportDir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a ArtifactPull) mergeJUnitReports(result Result, cfg Config, exportDir string) error {
+	a.logger.Println()
+
+	reports := export.SelectArtifacts(cfg.JUnitMergeSelection, result.Artifacts)
+	if len(reports) == 0 {
+		a.logger.Warnf("None of the pulled artifacts is selected as a JUnit report to merge")
+		return nil
+	}
+
+	mergedPath := filepath.Join(exportDir, mergedJUnitFileName)
+	totals, err := junit.MergeFiles(reports, mergedPath)
+	if err != nil {
+		return fmt.Errorf("failed to merge JUnit reports: %w", err)
+	}
+	a.logger.Printf("%d JUnit reports are merged (tests: %d, failures: %d, errors: %d, skipped: %d)", len(reports), totals.Tests, totals.Failures, totals.Errors, totals.Skipped)
+
+	if err := a.envRepository.Set("BITRISE_MERGED_JUNIT_PATH", mergedPath); err != nil {
+		return fmt.Errorf("failed to export merged JUnit report path, error: %s", err)
 	}
+	a.logger.Printf("$BITRISE_MERGED_JUNIT_PATH = %s", mergedPath)
 
-	return exporter.Export()
+	return nil
 }
 
 func dirNamePrefix(dirName string) (string, error) {
@@ -0,0 +1,167 @@
+package summary
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/bitrise-step-artifact-pull/humanize"
+)
+
+const (
+	StatusDownloaded = "downloaded"
+	StatusFailed     = "failed"
+)
+
+// Report collects the per-artifact results and the phase timings of a step run
+type Report struct {
+	Artifacts []Artifact `json:"artifacts"`
+	Phases    []Phase    `json:"phases"`
+
+	start time.Time
+}
+
+// Artifact is the download result of an artifact
+type Artifact struct {
+	Stage     string        `json:"stage,omitempty"`
+	Workflow  string        `json:"workflow,omitempty"`
+	BuildSlug string        `json:"build_slug"`
+	Title     string        `json:"title"`
+	Size      int64         `json:"size_bytes"`
+	Duration  time.Duration `json:"-"`
+	Status    string        `json:"status"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// Phase is a timed part of the step run, like the listing or the download of the artifacts
+type Phase struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"-"`
+}
+
+func NewReport() *Report {
+	return &Report{start: time.Now()}
+}
+
+// StartPhase starts timing a phase, the returned function stops it
+func (r *Report) StartPhase(name string) func() {
+	start := time.Now()
+
+	return func() {
+		r.Phases = append(r.Phases, Phase{Name: name, Duration: time.Since(start)})
+	}
+}
+
+func (r *Report) AddArtifact(artifact Artifact) {
+	r.Artifacts = append(r.Artifacts, artifact)
+}
+
+// Throughput returns the average download speed of the artifact in bytes per second
+func (a Artifact) Throughput() float64 {
+	if a.Duration <= 0 {
+		return 0
+	}
+
+	return float64(a.Size) / a.Duration.Seconds()
+}
+
+func (a Artifact) group() string {
+	switch {
+	case a.Stage != "" && a.Workflow != "":
+		return a.Stage + " / " + a.Workflow
+	case a.Workflow != "":
+		return a.Workflow
+	default:
+		return a.BuildSlug
+	}
+}
+
+// Print prints the artifacts grouped by stage and workflow, then the phase timings
+func (r *Report) Print(logger log.Logger) {
+	if len(r.Artifacts) > 0 {
+		var buf bytes.Buffer
+		w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "STAGE / WORKFLOW\tNAME\tSIZE\tDURATION\tTHROUGHPUT\tSTATUS")
+
+		var (
+			previousGroup string
+			totalSize     int64
+		)
+		for i, artifact := range r.Artifacts {
+			group := artifact.group()
+			if i > 0 && group == previousGroup {
+				group = ""
+			} else {
+				previousGroup = group
+			}
+
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s/s\t%s\n", group, artifact.Title, humanize.Bytes(artifact.Size), humanize.Duration(artifact.Duration), humanize.Bytes(int64(artifact.Throughput())), artifact.Status)
+			totalSize += artifact.Size
+		}
+		_ = w.Flush()
+
+		logger.Println()
+		logger.Infof("Summary of %d artifacts (%s)", len(r.Artifacts), humanize.Bytes(totalSize))
+		for _, line := range strings.Split(strings.TrimRight(buf.String(), "\n"), "\n") {
+			logger.Printf("%s", line)
+		}
+	}
+
+	if len(r.Phases) > 0 {
+		logger.Println()
+		logger.Infof("Timing breakdown (total: %s)", humanize.Duration(time.Since(r.start)))
+		for _, phase := range r.Phases {
+			logger.Printf("- %s: %s", phase.Name, humanize.Duration(phase.Duration))
+		}
+	}
+}
+
+type jsonArtifact struct {
+	Artifact
+	DurationMS int64   `json:"duration_ms"`
+	Throughput float64 `json:"throughput_bytes_per_second"`
+}
+
+type jsonPhase struct {
+	Phase
+	DurationMS int64 `json:"duration_ms"`
+}
+
+type jsonReport struct {
+	Artifacts       []jsonArtifact `json:"artifacts"`
+	Phases          []jsonPhase    `json:"phases"`
+	TotalSize       int64          `json:"total_size_bytes"`
+	TotalDurationMS int64          `json:"total_duration_ms"`
+}
+
+// WriteJSON writes the report into a JSON file
+func (r *Report) WriteJSON(pth string) error {
+	report := jsonReport{
+		Artifacts:       []jsonArtifact{},
+		Phases:          []jsonPhase{},
+		TotalDurationMS: time.Since(r.start).Milliseconds(),
+	}
+	for _, artifact := range r.Artifacts {
+		report.Artifacts = append(report.Artifacts, jsonArtifact{Artifact: artifact, DurationMS: artifact.Duration.Milliseconds(), Throughput: artifact.Throughput()})
+		report.TotalSize += artifact.Size
+	}
+	for _, phase := range r.Phases {
+		report.Phases = append(report.Phases, jsonPhase{Phase: phase, DurationMS: phase.Duration.Milliseconds()})
+	}
+
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(pth, content, 0o644); err != nil {
+		return fmt.Errorf("failed to write the summary: %w", err)
+	}
+
+	return nil
+}
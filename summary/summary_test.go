@@ -0,0 +1,102 @@
+package summary
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type messageLogger struct {
+	log.Logger
+	messages []string
+}
+
+func (l *messageLogger) Printf(format string, v ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, v...))
+}
+
+func (l *messageLogger) Infof(format string, v ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, v...))
+}
+
+func (l *messageLogger) Println() {}
+
+func testReport() *Report {
+	return &Report{
+		start: time.Now(),
+		Artifacts: []Artifact{
+			{Stage: "build", Workflow: "android", BuildSlug: "build-1", Title: "app.apk", Size: 2 * 1024 * 1024, Duration: 2 * time.Second, Status: StatusDownloaded},
+			{Stage: "build", Workflow: "android", BuildSlug: "build-1", Title: "mapping.txt", Size: 512, Duration: 10 * time.Millisecond, Status: StatusDownloaded},
+			{Workflow: "ios", BuildSlug: "build-2", Title: "app.ipa", Duration: time.Second, Status: StatusFailed, Error: "Status code: 403"},
+		},
+		Phases: []Phase{
+			{Name: "listing", Duration: 1500 * time.Millisecond},
+			{Name: "download", Duration: 3 * time.Second},
+		},
+	}
+}
+
+func TestReport_Print(t *testing.T) {
+	logger := &messageLogger{}
+	testReport().Print(logger)
+
+	assert.Equal(t, []string{
+		"Summary of 3 artifacts (2.0 MB)",
+		"STAGE / WORKFLOW  NAME         SIZE    DURATION  THROUGHPUT  STATUS",
+		"build / android   app.apk      2.0 MB  2s        1.0 MB/s    downloaded",
+		"                  mapping.txt  512 B   10ms      50.0 KB/s   downloaded",
+		"ios               app.ipa      0 B     1s        0 B/s       failed",
+	}, logger.messages[:5])
+	assert.Equal(t, []string{"- listing: 1.5s", "- download: 3s"}, logger.messages[6:])
+}
+
+func TestReport_StartPhase(t *testing.T) {
+	report := NewReport()
+	stop := report.StartPhase("listing")
+	stop()
+
+	assert.Len(t, report.Phases, 1)
+	assert.Equal(t, "listing", report.Phases[0].Name)
+}
+
+func TestReport_WriteJSON(t *testing.T) {
+	pth := filepath.Join(t.TempDir(), "summary.json")
+	assert.NoError(t, testReport().WriteJSON(pth))
+
+	content, err := ioutil.ReadFile(pth)
+	assert.NoError(t, err)
+
+	var report struct {
+		Artifacts []struct {
+			Title      string  `json:"title"`
+			Stage      string  `json:"stage"`
+			Size       int64   `json:"size_bytes"`
+			DurationMS int64   `json:"duration_ms"`
+			Throughput float64 `json:"throughput_bytes_per_second"`
+			Status     string  `json:"status"`
+			Error      string  `json:"error"`
+		} `json:"artifacts"`
+		Phases []struct {
+			Name       string `json:"name"`
+			DurationMS int64  `json:"duration_ms"`
+		} `json:"phases"`
+		TotalSize int64 `json:"total_size_bytes"`
+	}
+	assert.NoError(t, json.Unmarshal(content, &report))
+
+	assert.Len(t, report.Artifacts, 3)
+	assert.Equal(t, "app.apk", report.Artifacts[0].Title)
+	assert.Equal(t, "build", report.Artifacts[0].Stage)
+	assert.Equal(t, int64(2000), report.Artifacts[0].DurationMS)
+	assert.Equal(t, float64(1024*1024), report.Artifacts[0].Throughput)
+	assert.Equal(t, "Status code: 403", report.Artifacts[2].Error)
+	assert.Equal(t, "download", report.Phases[1].Name)
+	assert.Equal(t, int64(3000), report.Phases[1].DurationMS)
+	assert.Equal(t, int64(2*1024*1024+512), report.TotalSize)
+}